@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/murtaza-u/alertfy/internal/alert"
+	"github.com/murtaza-u/alertfy/internal/conf"
+
+	flag "github.com/spf13/pflag"
+)
+
+// checkConfig validates the config file and executes every template and
+// expression against a sample firing and a sample resolved alert. Every
+// problem is reported along with its path, and an error is returned if any
+// was found.
+//
+//	alertfy check-config [--conf path] [--label name=value] [--annotation name=value]
+func checkConfig(args []string) error {
+	f := flag.NewFlagSet("check-config", flag.ContinueOnError)
+	f.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: alertfy check-config [flags]")
+		fmt.Fprint(os.Stderr, f.FlagUsages())
+	}
+	conf.RegisterFlags(f)
+	labels := f.StringToString("label", nil, "additional labels of the sample alerts")
+	annotations := f.StringToString("annotation", nil, "additional annotations of the sample alerts")
+	if err := f.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	c, err := conf.New(args...)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	if err := c.Validate(); err != nil {
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, err := range joined.Unwrap() {
+				problems = append(problems, err.Error())
+			}
+		} else {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	ch := checker{samples: samples(*labels, *annotations)}
+	ch.ntfy("ntfy", c.Ntfy)
+	for _, name := range sortedKeys(c.Endpoints) {
+		ch.ntfy("endpoints."+name, c.Endpoints[name])
+	}
+	problems = append(problems, ch.problems...)
+
+	path := conf.Path(args...)
+	if len(problems) != 0 {
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, p)
+		}
+		return fmt.Errorf("%s: found %d problem(s)", path, len(problems))
+	}
+	fmt.Printf("%s: configuration is valid\n", path)
+	return nil
+}
+
+// sample is a webhook payload the templates and expressions are executed
+// against.
+type sample struct {
+	// name describes the sample in reported problems.
+	name    string
+	webhook alert.Webhook
+}
+
+// data returns the value templates and expressions are evaluated against in
+// the provided mode.
+func (s sample) data(mode string) any {
+	if mode == conf.ModeGroup {
+		return s.webhook
+	}
+	return alert.NewData(s.webhook, s.webhook.Alerts[0])
+}
+
+// samples returns a firing and a resolved sample alert. The provided labels
+// and annotations are added to, or override, the built-in ones, so that
+// expressions referring to labels specific to the deployment can be
+// evaluated.
+func samples(labels, annotations map[string]string) []sample {
+	now := time.Now()
+	out := make([]sample, 0, 2)
+	for _, status := range []string{"firing", "resolved"} {
+		a := alert.Alert{
+			Status: status,
+			Labels: map[string]string{
+				"alertname": "SampleAlert",
+				"severity":  "critical",
+				"instance":  "localhost:9100",
+				"job":       "node",
+			},
+			Annotations: map[string]string{
+				"summary":     "Sample alert",
+				"description": "This is a sample alert rendered by `alertfy check-config`.",
+			},
+			StartsAt:     now.Add(-5 * time.Minute),
+			GeneratorURL: "http://localhost:9090/graph",
+			Fingerprint:  "0123456789abcdef",
+		}
+		if status == "resolved" {
+			a.EndsAt = now
+		}
+		maps.Copy(a.Labels, labels)
+		maps.Copy(a.Annotations, annotations)
+		out = append(out, sample{
+			name: status + " alert",
+			webhook: alert.Webhook{
+				Version:           "4",
+				GroupKey:          `{}:{alertname="SampleAlert"}`,
+				Status:            status,
+				Receiver:          "alertfy",
+				GroupLabels:       map[string]string{"alertname": "SampleAlert"},
+				CommonLabels:      maps.Clone(a.Labels),
+				CommonAnnotations: maps.Clone(a.Annotations),
+				ExternalURL:       "http://localhost:9093",
+				Alerts:            alert.Alerts{a},
+			},
+		})
+	}
+	return out
+}
+
+// checker executes templates and expressions against the samples, recording
+// every failure along with the path of the offending option.
+type checker struct {
+	samples  []sample
+	problems []string
+}
+
+// ntfy checks the notification configuration, including the routes, of the
+// ntfy configuration at the provided path.
+func (ch *checker) ntfy(path string, n conf.Ntfy) {
+	mode := n.Notification.Mode
+	ch.notification(path+".notification", mode, n.Notification)
+	ch.routes(path+".routes", mode, n.Routes)
+	if n.Notifier == conf.NotifierWebhook {
+		ch.template(path+".webhook.method", mode, n.Webhook.Method)
+		for _, name := range sortedKeys(n.Webhook.Headers) {
+			ch.template(path+".webhook.headers."+name, mode, n.Webhook.Headers[name])
+		}
+		ch.template(path+".webhook.body", mode, n.Webhook.Body)
+	}
+	if n.Notifier == conf.NotifierEmail || n.Fallback.Enable {
+		ch.template(path+".email.html", mode, n.Email.HTML)
+	}
+}
+
+// routes checks the options set on the routes. Inherited options are checked
+// where they are set.
+func (ch *checker) routes(path, mode string, routes []conf.Route) {
+	for i, r := range routes {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		ch.notification(p+".notification", mode, r.Notification)
+		ch.routes(p+".routes", mode, r.Routes)
+	}
+}
+
+func (ch *checker) notification(path, mode string, n conf.Notification) {
+	ch.template(path+".title", mode, n.Title)
+	ch.template(path+".description", mode, n.Description)
+	ch.stringExpr(path+".topic", mode, n.Topic)
+	for i, t := range n.Topics {
+		p := fmt.Sprintf("%s.topics[%d]", path, i)
+		ch.stringExpr(p+".topic", mode, t.Topic)
+		ch.expr(p+".condition", mode, t.Condition)
+	}
+	ch.stringExpr(path+".priority", mode, n.Priority)
+	for i, t := range n.Tags {
+		ch.expr(fmt.Sprintf("%s.tags[%d].condition", path, i), mode, t.Condition)
+	}
+}
+
+func (ch *checker) template(path, mode string, t *conf.Template) {
+	if t == nil {
+		return
+	}
+	for _, s := range ch.samples {
+		if err := t.Execute(new(bytes.Buffer), s.data(mode)); err != nil {
+			ch.fail(path, s, fmt.Errorf("executing template: %w", err))
+		}
+	}
+}
+
+func (ch *checker) stringExpr(path, mode string, se conf.StringExpr) {
+	if se.Expr == nil {
+		return
+	}
+	for _, s := range ch.samples {
+		_, err := se.Expr.Evaluable.EvalString(context.Background(), s.data(mode))
+		if err != nil {
+			ch.fail(path, s, fmt.Errorf("evaluating expression %q: %w", se.Text, err))
+		}
+	}
+}
+
+func (ch *checker) expr(path, mode string, e conf.Expr) {
+	if e.Text == "" {
+		return
+	}
+	for _, s := range ch.samples {
+		_, err := e.Evaluable.EvalBool(context.Background(), s.data(mode))
+		if err != nil {
+			ch.fail(path, s, fmt.Errorf("evaluating expression %q: %w", e.Text, err))
+		}
+	}
+}
+
+// sortedKeys returns the keys of the map in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func (ch *checker) fail(path string, s sample, err error) {
+	ch.problems = append(ch.problems, fmt.Sprintf("`%s` (%s): %s", path, s.name, err))
+}
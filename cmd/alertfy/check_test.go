@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckConfig(t *testing.T) {
+	a := assert.New(t)
+
+	path := writeFile(t, "config.yaml", `
+ntfy:
+  baseUrl: https://ntfy.sh
+  notification:
+    topic: alerts
+    title: '{{ .Labels.alertname }}'
+    description: '{{ .Annotations.summary }}'
+`)
+	res := run(t, "check-config", "--conf", path)
+	a.Zero(res.code, res.stderr)
+	a.Equal(path+": configuration is valid\n", res.stdout)
+
+	path = writeFile(t, "config.yaml", `
+hook:
+  tls:
+    clientCAFile: ca.pem
+  log:
+    level: verbose
+ntfy:
+  baseUrl: https://ntfy.sh
+  notification:
+    topic: alerts
+    title: '{{ .Missing }}'
+    description: '{{ .Annotations.summary }}'
+endpoints:
+  db:
+    notifier: gotify
+    notification:
+      topic: db
+      title: '{{ .Labels.alertname }}'
+      description: '{{ .Annotations.summary }}'
+delivery:
+  retry:
+    maxAttempts: 0
+    jitter: 2
+`)
+	res = run(t, "check-config", "--conf", path)
+	a.NotZero(res.code)
+	a.Empty(res.stdout)
+	for _, want := range []string{
+		"`hook.tls.clientCAFile`: is set but TLS is not enabled",
+		"`hook.log.level`: invalid value \"verbose\"",
+		"`endpoints.db.baseUrl`: is required for gotify",
+		"`endpoints.db.gotify.token`: is required for gotify",
+		"`delivery.retry.maxAttempts`: must be at least 1",
+		"`delivery.retry.jitter`: must be between 0 and 1",
+		"`ntfy.notification.title` (firing alert): executing template",
+		"`ntfy.notification.title` (resolved alert): executing template",
+		"found 8 problem(s)",
+	} {
+		a.Contains(res.stderr, want)
+	}
+	a.Equal(8, strings.Count("\n"+res.stderr, "\n`"), res.stderr)
+}
@@ -1,15 +1,38 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
 	"github.com/murtaza-u/alertfy/internal/conf"
 	"github.com/murtaza-u/alertfy/internal/hook"
+	"github.com/murtaza-u/alertfy/internal/tracing"
 )
 
+// commands are the subcommands of alertfy. Without a subcommand, the webhook
+// is served.
+var commands = map[string]func(args []string) error{
+	"check-config": checkConfig,
+	"render":       render,
+	"routes":       routes,
+	"test":         test,
+}
+
 func main() {
-	conf, err := conf.New(os.Args[1:]...)
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+	serve(os.Args[1:])
+}
+
+func serve(args []string) {
+	conf, err := conf.New(args...)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -17,9 +40,17 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to validate provided config: %s", err.Error())
 	}
+	shutdown, err := tracing.Setup(conf.Tracing)
+	if err != nil {
+		log.Fatalf("failed to set up tracing: %s", err.Error())
+	}
 	hook, err := hook.New(*conf)
 	if err != nil {
 		log.Fatal(err)
 	}
+	go watchConfig(args, hook)
 	hook.Listen()
+	if err := shutdown(context.Background()); err != nil {
+		log.Printf("failed to flush traces: %s", err.Error())
+	}
 }
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain runs alertfy instead of the tests when ALERTFY_TEST_MAIN is set, so
+// that the tests can check the output and exit status of the subcommands.
+func TestMain(m *testing.M) {
+	if os.Getenv("ALERTFY_TEST_MAIN") != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// result is the outcome of running alertfy.
+type result struct {
+	stdout string
+	stderr string
+	code   int
+}
+
+// run runs alertfy with the provided arguments.
+func run(t *testing.T, args ...string) result {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "ALERTFY_TEST_MAIN=1")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if err != nil && !errors.As(err, &exit) {
+		t.Fatalf("running alertfy: %s", err)
+	}
+	return result{
+		stdout: stdout.String(),
+		stderr: stderr.String(),
+		code:   cmd.ProcessState.ExitCode(),
+	}
+}
+
+// writeFile writes the content to a file named name in a temporary directory
+// and returns its path.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/hook"
+
+	"github.com/knadh/koanf/providers/file"
+)
+
+const (
+	// reloadDelay is how long to wait for further changes before reloading
+	// the configuration, as the config file may be written in several steps.
+	reloadDelay = 500 * time.Millisecond
+	// rewatchInterval is how often to try watching the config file again
+	// once it could no longer be watched, for example because it was
+	// replaced.
+	rewatchInterval = time.Second
+)
+
+// watchConfig reloads the configuration of the webhook whenever SIGHUP is
+// received or the config file changes. Invalid configurations are logged and
+// otherwise ignored.
+func watchConfig(args []string, h *hook.Hook) {
+	reload := make(chan struct{}, 1)
+	trigger := func() {
+		select {
+		case reload <- struct{}{}:
+		default:
+		}
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			slog.LogAttrs(
+				context.Background(),
+				slog.LevelInfo,
+				"received SIGHUP. Reloading configuration",
+			)
+			trigger()
+		}
+	}()
+	go watchFile(conf.Path(args...), trigger)
+
+	for range reload {
+		time.Sleep(reloadDelay)
+		select {
+		case <-reload:
+		default:
+		}
+
+		c, err := conf.New(args...)
+		if err == nil {
+			err = c.Validate()
+		}
+		if err == nil {
+			err = h.Reload(*c)
+		}
+		if err != nil {
+			slog.LogAttrs(
+				context.Background(),
+				slog.LevelError,
+				"failed to reload configuration. Keeping the previous one",
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+}
+
+// watchFile calls onChange whenever the file at path is written to or
+// replaced. If the file can no longer be watched, it is watched again as
+// soon as possible, and onChange is called once it is.
+func watchFile(path string, onChange func()) {
+	for watched := false; ; time.Sleep(rewatchInterval) {
+		stopped := make(chan error, 1)
+		err := file.Provider(path).Watch(func(_ any, err error) {
+			if err != nil {
+				stopped <- err
+				return
+			}
+			onChange()
+		})
+		if err != nil {
+			continue
+		}
+		if watched {
+			// the file may have changed while it was not watched
+			onChange()
+		}
+		watched = true
+
+		err = <-stopped
+		slog.LogAttrs(
+			context.Background(),
+			slog.LevelWarn,
+			"stopped watching config file. Watching it again",
+			slog.String("file", path),
+			slog.String("error", err.Error()),
+		)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/murtaza-u/alertfy/internal/alert"
+	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/ntfy"
+
+	flag "github.com/spf13/pflag"
+)
+
+// render reads an Alertmanager webhook payload and prints the requests that
+// would be sent for it, without sending anything. Credentials are left out of
+// the printed requests.
+//
+//	alertfy render [--conf path] [--endpoint name] --payload path
+func render(args []string) error {
+	f := flag.NewFlagSet("render", flag.ContinueOnError)
+	f.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: alertfy render [flags] --payload path")
+		fmt.Fprint(os.Stderr, f.FlagUsages())
+	}
+	conf.RegisterFlags(f)
+	endpoint := f.String("endpoint", "", "name of the webhook endpoint")
+	payload := f.String("payload", "", "path to the Alertmanager webhook JSON payload")
+	if err := f.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if *payload == "" {
+		return errors.New("`--payload` is required")
+	}
+
+	c, err := conf.New(args...)
+	if err != nil {
+		return err
+	}
+	if err := c.Validate(); err != nil {
+		return fmt.Errorf("failed to validate provided config: %w", err)
+	}
+	n, ok := c.Endpoint(*endpoint)
+	if !ok {
+		return fmt.Errorf("unknown endpoint %q", *endpoint)
+	}
+
+	w, err := readPayload(*payload)
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, r := range ntfy.Render(context.Background(), n, w) {
+		subject := fmt.Sprintf("group %s (%s)", w.GroupKey, w.Status)
+		if r.Alert != nil {
+			subject = fmt.Sprintf("alert %s (%s)", r.Alert.Fingerprint, r.Alert.Status)
+		}
+		if r.Route.ID != "" {
+			subject += " route " + r.Route.ID
+		}
+		fmt.Printf("# %s\n", subject)
+		if r.Err != nil {
+			failed++
+			fmt.Printf("error: %s\n\n", r.Err.Error())
+			continue
+		}
+		if len(r.Notifications) == 0 {
+			fmt.Print("(no topic matched)\n\n")
+			continue
+		}
+		for _, d := range r.Notifications {
+			if err := printRequest(n.Notifier, d); err != nil {
+				failed++
+				fmt.Printf("error: %s\n\n", err.Error())
+			}
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("failed to render %d notification(s)", failed)
+	}
+	return nil
+}
+
+// readPayload reads the Alertmanager webhook payload from the file at path.
+func readPayload(path string) (alert.Webhook, error) {
+	var w alert.Webhook
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return w, fmt.Errorf("failed to read payload: %w", err)
+	}
+	if err := json.Unmarshal(b, &w); err != nil {
+		return w, fmt.Errorf("failed to parse payload %s: %w", path, err)
+	}
+	if len(w.Alerts) == 0 {
+		return w, fmt.Errorf("payload %s contains zero alerts", path)
+	}
+	return w, nil
+}
+
+// printRequest prints the request sent for the notification by the ntfy and
+// webhook notifiers. For the other notifiers, the rendered notification is
+// printed instead.
+func printRequest(notifier string, d ntfy.Data) error {
+	var req *http.Request
+	var err error
+	switch notifier {
+	case conf.NotifierNtfy:
+		req, err = ntfy.NewRequest(context.Background(), ntfy.RequestData{Notification: d})
+	case conf.NotifierWebhook:
+		req, err = http.NewRequest(d.Webhook.Method, d.URL, strings.NewReader(d.Webhook.Body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+			for name, value := range d.Webhook.Headers {
+				req.Header.Set(name, value)
+			}
+		}
+	default:
+		fmt.Printf("%s notification to topic %q\n", notifier, d.Topic)
+		fmt.Printf("priority: %s\ntags: %s\ntitle: %s\n\n%s\n\n",
+			d.Priority, d.Tags, d.Title, d.Description)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("creating http request: %w", err)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s %s\n", req.Method, req.URL)
+	if err := req.Header.Write(os.Stdout); err != nil {
+		return err
+	}
+	fmt.Printf("\n%s\n\n", body)
+	return nil
+}
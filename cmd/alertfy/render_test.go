@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	a := assert.New(t)
+	path := writeFile(t, "config.yaml", `
+ntfy:
+  baseUrl: https://ntfy.example.com
+  auth:
+    enable: true
+    username: admin
+    password: s3cret-password
+  notification:
+    topic: alerts
+    title: '[{{ .Status }}] {{ .Labels.alertname }}'
+    description: '{{ .Annotations.summary }}'
+    priority: |
+      Labels.severity == "critical" ? "urgent" : "default"
+    tags:
+      - tag: rotating_light
+        condition: Status == "firing"
+      - tag: white_check_mark
+        condition: Status == "resolved"
+endpoints:
+  gotify:
+    notifier: gotify
+    baseUrl: https://gotify.example.com
+    gotify:
+      token: s3cret-token
+    notification:
+      title: '{{ .Labels.alertname }}'
+      description: '{{ .Annotations.summary }}'
+`)
+
+	res := run(t, "render", "--conf", path, "--payload", "testdata/payload.json")
+	a.Zero(res.code, res.stderr)
+	a.Equal("# alert 4f2a9c1e8b7d6053 (firing)\n"+
+		"POST https://ntfy.example.com/alerts\n"+
+		"X-Priority: urgent\r\n"+
+		"X-Tags: rotating_light\r\n"+
+		"X-Title: [firing] HighLatency\r\n"+
+		"\nLatency is high\n\n", res.stdout)
+	a.NotContains(res.stdout, "Authorization")
+	a.NotContains(res.stdout, "s3cret-password")
+
+	res = run(t, "render", "--conf", path, "--endpoint", "gotify",
+		"--payload", "testdata/payload.json")
+	a.Zero(res.code, res.stderr)
+	a.Contains(res.stdout, "title: HighLatency\n\nLatency is high\n")
+	a.NotContains(res.stdout, "s3cret-token")
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/murtaza-u/alertfy/internal/alert"
+	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/ntfy"
+
+	flag "github.com/spf13/pflag"
+)
+
+// routes prints the routes an alert with the provided labels matches, along
+// with the notification rendered for each of them.
+//
+//	alertfy routes [--conf path] [--endpoint name] [--status status] label=value...
+func routes(args []string) error {
+	f := flag.NewFlagSet("routes", flag.ContinueOnError)
+	f.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: alertfy routes [flags] label=value...")
+		fmt.Fprint(os.Stderr, f.FlagUsages())
+	}
+	conf.RegisterFlags(f)
+	endpoint := f.String("endpoint", "", "name of the webhook endpoint")
+	status := f.String("status", "firing", "status of the alert")
+	annotations := f.StringToString("annotation", nil, "annotations of the alert")
+	if err := f.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	labels := make(map[string]string, f.NArg())
+	for _, arg := range f.Args() {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("invalid label %q: expected `name=value`", arg)
+		}
+		labels[name] = value
+	}
+
+	c, err := conf.New(args...)
+	if err != nil {
+		return err
+	}
+	if err := c.Validate(); err != nil {
+		return fmt.Errorf("failed to validate provided config: %w", err)
+	}
+	n, ok := c.Endpoint(*endpoint)
+	if !ok {
+		return fmt.Errorf("unknown endpoint %q", *endpoint)
+	}
+
+	a := alert.Alert{Status: *status, Labels: labels, Annotations: *annotations}
+	w := alert.Webhook{
+		Status:            *status,
+		CommonLabels:      labels,
+		CommonAnnotations: *annotations,
+		Alerts:            alert.Alerts{a},
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, r := range ntfy.Render(context.Background(), n, w) {
+		if r.Route.ID == "" {
+			fmt.Fprintln(tw, "(no route matched)")
+		} else {
+			fmt.Fprintf(tw, "route %s\n", r.Route.ID)
+		}
+
+		if r.Err != nil {
+			fmt.Fprintf(tw, "  error:\t%s\n", r.Err.Error())
+			continue
+		}
+		notifications := r.Notifications
+		if len(notifications) == 0 {
+			fmt.Fprintln(tw, "  (no topic matched)")
+			continue
+		}
+		// notifications only differ in their topic
+		topics := make([]string, len(notifications))
+		for i, d := range notifications {
+			topics[i] = d.Topic
+		}
+		data := notifications[0]
+		fmt.Fprintf(tw, "  topic:\t%s\n", strings.Join(topics, ", "))
+		fmt.Fprintf(tw, "  priority:\t%s\n", data.Priority)
+		fmt.Fprintf(tw, "  tags:\t%s\n", data.Tags)
+		fmt.Fprintf(tw, "  title:\t%s\n", strings.TrimSpace(data.Title))
+		fmt.Fprintf(tw, "  description:\t%s\n", strings.TrimSpace(data.Description))
+		if data.Webhook != nil {
+			fmt.Fprintf(tw, "  request:\t%s %s\n", data.Webhook.Method, data.URL)
+			fmt.Fprintf(tw, "  body:\t%s\n", data.Webhook.Body)
+		}
+	}
+	return tw.Flush()
+}
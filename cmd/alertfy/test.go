@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/murtaza-u/alertfy/internal/alert"
+	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/ntfy"
+
+	flag "github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// test evaluates the test cases of the provided test files against the
+// configuration and prints the differences between the expected and the
+// rendered notifications.
+//
+//	alertfy test [--conf path] tests.yaml...
+func test(args []string) error {
+	f := flag.NewFlagSet("test", flag.ContinueOnError)
+	f.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: alertfy test [flags] tests.yaml...")
+		fmt.Fprint(os.Stderr, f.FlagUsages())
+	}
+	conf.RegisterFlags(f)
+	if err := f.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if f.NArg() == 0 {
+		f.Usage()
+		return errors.New("no test file provided")
+	}
+
+	c, err := conf.New(args...)
+	if err != nil {
+		return err
+	}
+	if err := c.Validate(); err != nil {
+		return fmt.Errorf("failed to validate provided config: %w", err)
+	}
+
+	var total, failed int
+	for _, path := range f.Args() {
+		cases, err := readTests(path)
+		if err != nil {
+			return err
+		}
+		for i, tc := range cases {
+			name := tc.Name
+			if name == "" {
+				name = fmt.Sprintf("%s: tests[%d]", path, i)
+			}
+			total++
+			diffs := tc.run(*c)
+			if len(diffs) == 0 {
+				fmt.Printf("--- PASS: %s\n", name)
+				continue
+			}
+			failed++
+			fmt.Printf("--- FAIL: %s\n", name)
+			for _, d := range diffs {
+				fmt.Printf("    %s\n", strings.ReplaceAll(d, "\n", "\n    "))
+			}
+		}
+	}
+
+	if failed != 0 {
+		fmt.Println("FAIL")
+		return fmt.Errorf("%d of %d test(s) failed", failed, total)
+	}
+	fmt.Printf("PASS: %d test(s)\n", total)
+	return nil
+}
+
+// testFile is the format of test files.
+type testFile struct {
+	Tests []testCase `yaml:"tests"`
+}
+
+// testCase is a webhook payload along with the notifications expected to be
+// rendered for it. In "alert" mode, the notifications of every alert are
+// expected in order.
+type testCase struct {
+	// Name identifies the test case in the output. If empty, the test case
+	// is identified by its position in the file.
+	Name string `yaml:"name"`
+	// Endpoint is the name of the webhook endpoint the payload is received
+	// on. Empty for the default endpoint.
+	Endpoint    string            `yaml:"endpoint"`
+	Receiver    string            `yaml:"receiver"`
+	GroupKey    string            `yaml:"groupKey"`
+	GroupLabels map[string]string `yaml:"groupLabels"`
+	ExternalURL string            `yaml:"externalURL"`
+	// Alerts of the payload. The status of the group, as well as the common
+	// labels and annotations, are derived from them.
+	Alerts []testAlert `yaml:"alerts"`
+	// Notifications are the expected notifications. Unset fields are not
+	// compared.
+	Notifications []expected `yaml:"notifications"`
+}
+
+// testAlert is an alert of a test case.
+type testAlert struct {
+	// Status of the alert.
+	//
+	// Default: "firing"
+	Status       string            `yaml:"status"`
+	Labels       map[string]string `yaml:"labels"`
+	Annotations  map[string]string `yaml:"annotations"`
+	StartsAt     time.Time         `yaml:"startsAt"`
+	EndsAt       time.Time         `yaml:"endsAt"`
+	GeneratorURL string            `yaml:"generatorURL"`
+	// Fingerprint of the alert.
+	//
+	// Default: the position of the alert in the test case
+	Fingerprint string `yaml:"fingerprint"`
+}
+
+// expected is an expected notification.
+type expected struct {
+	// Route is the ID of the route the notification is rendered for, as
+	// printed by the `routes` command. Empty if no route matched.
+	Route       *match `yaml:"route"`
+	Topic       *match `yaml:"topic"`
+	Priority    *match `yaml:"priority"`
+	Tags        *match `yaml:"tags"`
+	Title       *match `yaml:"title"`
+	Description *match `yaml:"description"`
+}
+
+// match is an expected value. It is either compared exactly, if set to a
+// string, or matched against a regular expression anchored at both ends, if
+// set to `{regex: expression}`.
+type match struct {
+	exact string
+	// pattern is the regular expression as written in the test file.
+	pattern string
+	regex   *regexp.Regexp
+}
+
+func (m *match) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&m.exact)
+	}
+	var v struct {
+		Regex string `yaml:"regex"`
+	}
+	if err := node.Decode(&v); err != nil {
+		return err
+	}
+	re, err := regexp.Compile("^(?:" + v.Regex + ")$")
+	if err != nil {
+		return fmt.Errorf("line %d: invalid regex %q: %w", node.Line, v.Regex, err)
+	}
+	m.pattern = v.Regex
+	m.regex = re
+	return nil
+}
+
+// diff returns the difference between the expected and the actual value, or
+// an empty string if the value matches. Leading and trailing whitespace is
+// ignored.
+func (m *match) diff(field, got string) string {
+	if m == nil {
+		return ""
+	}
+	got = strings.TrimSpace(got)
+	want := strconv.Quote(strings.TrimSpace(m.exact))
+	if m.regex != nil {
+		if m.regex.MatchString(got) {
+			return ""
+		}
+		want = "/" + m.pattern + "/"
+	} else if got == strings.TrimSpace(m.exact) {
+		return ""
+	}
+	return fmt.Sprintf("%s:\n- %s\n+ %q", field, want, got)
+}
+
+// readTests reads the test cases of the test file at path.
+func readTests(path string) ([]testCase, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read test file: %w", err)
+	}
+	var file testFile
+	if err := yaml.Unmarshal(b, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse test file %s: %w", path, err)
+	}
+	return file.Tests, nil
+}
+
+// run renders the notifications for the test case and returns the
+// differences with the expected notifications.
+func (tc testCase) run(c conf.C) []string {
+	n, ok := c.Endpoint(tc.Endpoint)
+	if !ok {
+		return []string{fmt.Sprintf("unknown endpoint %q", tc.Endpoint)}
+	}
+	if len(tc.Alerts) == 0 {
+		return []string{"the test case has zero alerts"}
+	}
+
+	type notification struct {
+		route string
+		data  ntfy.Data
+	}
+	var got []notification
+	var diffs []string
+	for _, r := range ntfy.Render(context.Background(), n, tc.webhook()) {
+		if r.Err != nil {
+			subject := "group"
+			if r.Alert != nil {
+				subject = "alert " + r.Alert.Fingerprint
+			}
+			diffs = append(diffs, fmt.Sprintf("%s: %s", subject, r.Err.Error()))
+			continue
+		}
+		for _, d := range r.Notifications {
+			got = append(got, notification{route: r.Route.ID, data: d})
+		}
+	}
+	if len(diffs) != 0 {
+		return diffs
+	}
+
+	for i, want := range tc.Notifications {
+		if i >= len(got) {
+			break
+		}
+		g := got[i]
+		field := func(name string) string {
+			return fmt.Sprintf("notifications[%d].%s", i, name)
+		}
+		for _, d := range []string{
+			want.Route.diff(field("route"), g.route),
+			want.Topic.diff(field("topic"), g.data.Topic),
+			want.Priority.diff(field("priority"), g.data.Priority),
+			want.Tags.diff(field("tags"), g.data.Tags),
+			want.Title.diff(field("title"), g.data.Title),
+			want.Description.diff(field("description"), g.data.Description),
+		} {
+			if d != "" {
+				diffs = append(diffs, d)
+			}
+		}
+	}
+	if len(got) != len(tc.Notifications) {
+		d := fmt.Sprintf("expected %d notification(s), got %d:", len(tc.Notifications), len(got))
+		for _, g := range got {
+			route := g.route
+			if route == "" {
+				route = "(no route matched)"
+			}
+			d += fmt.Sprintf("\n  route %s, topic %q, title %q",
+				route, g.data.Topic, strings.TrimSpace(g.data.Title))
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs
+}
+
+// webhook returns the webhook payload of the test case. The group is firing
+// if any of its alerts is, and the common labels and annotations are the
+// ones shared by all alerts.
+func (tc testCase) webhook() alert.Webhook {
+	w := alert.Webhook{
+		Version:     "4",
+		GroupKey:    tc.GroupKey,
+		Status:      "resolved",
+		Receiver:    tc.Receiver,
+		GroupLabels: tc.GroupLabels,
+		ExternalURL: tc.ExternalURL,
+	}
+	for i, ta := range tc.Alerts {
+		a := alert.Alert{
+			Status:       ta.Status,
+			Labels:       ta.Labels,
+			Annotations:  ta.Annotations,
+			StartsAt:     ta.StartsAt,
+			EndsAt:       ta.EndsAt,
+			GeneratorURL: ta.GeneratorURL,
+			Fingerprint:  ta.Fingerprint,
+		}
+		if a.Status == "" {
+			a.Status = "firing"
+		}
+		if a.Fingerprint == "" {
+			a.Fingerprint = strconv.Itoa(i)
+		}
+		if a.Status == "firing" {
+			w.Status = "firing"
+		}
+		w.Alerts = append(w.Alerts, a)
+	}
+	w.CommonLabels = common(w.Alerts, func(a alert.Alert) map[string]string { return a.Labels })
+	w.CommonAnnotations = common(w.Alerts, func(a alert.Alert) map[string]string { return a.Annotations })
+	return w
+}
+
+// common returns the key-value pairs of the map that are shared by all
+// alerts.
+func common(alerts alert.Alerts, m func(alert.Alert) map[string]string) map[string]string {
+	out := maps.Clone(m(alerts[0]))
+	if out == nil {
+		out = make(map[string]string)
+	}
+	for _, a := range alerts[1:] {
+		other := m(a)
+		maps.DeleteFunc(out, func(k, v string) bool {
+			ov, ok := other[k]
+			return !ok || ov != v
+		})
+	}
+	return out
+}
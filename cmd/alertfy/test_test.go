@@ -0,0 +1,241 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMatchUnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		exact   string
+		pattern string
+		err     string
+	}{
+		{name: "plain value", input: "title: High latency", exact: "High latency"},
+		{name: "regex", input: "title: {regex: 'High .*'}", pattern: "High .*"},
+		{
+			name:  "invalid regex",
+			input: "title: {regex: 'High ('}",
+			err:   `line 1: invalid regex "High ("`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := assert.New(t)
+			var v struct {
+				Title *match `yaml:"title"`
+			}
+			err := yaml.Unmarshal([]byte(tt.input), &v)
+			if tt.err != "" {
+				a.ErrorContains(err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			a.Equal(tt.exact, v.Title.exact)
+			a.Equal(tt.pattern, v.Title.pattern)
+			a.Equal(tt.pattern != "", v.Title.regex != nil)
+		})
+	}
+}
+
+func TestMatchDiff(t *testing.T) {
+	tests := []struct {
+		name  string
+		match *match
+		got   string
+		diff  string
+	}{
+		{name: "unset", got: "anything"},
+		{name: "exact", match: &match{exact: "High latency"}, got: "High latency"},
+		{name: "surrounding whitespace", match: &match{exact: " High latency\n"}, got: "High latency\n"},
+		{
+			name:  "exact mismatch",
+			match: &match{exact: "High latency"},
+			got:   "Low latency",
+			diff:  "title:\n- \"High latency\"\n+ \"Low latency\"",
+		},
+		{name: "regex", match: newMatch(t, "High .*"), got: "High latency"},
+		{
+			name:  "regex is anchored",
+			match: newMatch(t, "latency"),
+			got:   "High latency",
+			diff:  "title:\n- /latency/\n+ \"High latency\"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.diff, tt.match.diff("title", tt.got))
+		})
+	}
+}
+
+// newMatch returns a match of the provided regular expression.
+func newMatch(t *testing.T, pattern string) *match {
+	t.Helper()
+	var m match
+	require.NoError(t, yaml.Unmarshal([]byte("{regex: '"+pattern+"'}"), &m))
+	return &m
+}
+
+func TestTestCaseWebhook(t *testing.T) {
+	tests := []struct {
+		name   string
+		alerts []testAlert
+		status string
+		labels map[string]string
+	}{
+		{
+			name:   "firing by default",
+			alerts: []testAlert{{Labels: map[string]string{"alertname": "A"}}},
+			status: "firing",
+			labels: map[string]string{"alertname": "A"},
+		},
+		{
+			name: "firing if any alert is",
+			alerts: []testAlert{
+				{Status: "resolved", Labels: map[string]string{"alertname": "A", "job": "db"}},
+				{Labels: map[string]string{"alertname": "A", "job": "api"}},
+			},
+			status: "firing",
+			labels: map[string]string{"alertname": "A"},
+		},
+		{
+			name: "resolved if all alerts are",
+			alerts: []testAlert{
+				{Status: "resolved", Labels: map[string]string{"alertname": "A"}},
+				{Status: "resolved", Labels: map[string]string{"alertname": "B"}},
+			},
+			status: "resolved",
+			labels: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := assert.New(t)
+			w := testCase{Alerts: tt.alerts}.webhook()
+			a.Equal(tt.status, w.Status)
+			a.Equal(tt.labels, w.CommonLabels)
+			for i, alert := range w.Alerts {
+				a.NotEmpty(alert.Status)
+				a.NotEmpty(alert.Fingerprint, "alerts[%d]", i)
+			}
+		})
+	}
+}
+
+func TestTestCaseRun(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+ntfy:
+  baseUrl: https://ntfy.sh
+  notification:
+    topic: alerts
+    title: '{{ .Labels.alertname }}'
+    description: '{{ .Annotations.summary }}'
+`)
+	c, err := conf.New("--conf", path)
+	require.NoError(t, err)
+
+	alerts := []testAlert{
+		{Labels: map[string]string{"alertname": "A"}},
+		{Labels: map[string]string{"alertname": "B"}},
+	}
+	tests := []struct {
+		name          string
+		endpoint      string
+		notifications []expected
+		diffs         []string
+	}{
+		{
+			name: "in order",
+			notifications: []expected{
+				{Title: &match{exact: "A"}},
+				{Title: &match{exact: "B"}, Topic: &match{exact: "alerts"}},
+			},
+		},
+		{
+			name: "out of order",
+			notifications: []expected{
+				{Title: &match{exact: "B"}},
+				{Title: &match{exact: "A"}},
+			},
+			diffs: []string{
+				"notifications[0].title:\n- \"B\"\n+ \"A\"",
+				"notifications[1].title:\n- \"A\"\n+ \"B\"",
+			},
+		},
+		{
+			name:          "too few",
+			notifications: []expected{{Title: &match{exact: "A"}}},
+			diffs: []string{"expected 1 notification(s), got 2:\n" +
+				"  route (no route matched), topic \"alerts\", title \"A\"\n" +
+				"  route (no route matched), topic \"alerts\", title \"B\""},
+		},
+		{
+			name: "too many",
+			notifications: []expected{
+				{Title: &match{exact: "A"}},
+				{Title: &match{exact: "B"}},
+				{Title: &match{exact: "C"}},
+			},
+			diffs: []string{"expected 3 notification(s), got 2:\n" +
+				"  route (no route matched), topic \"alerts\", title \"A\"\n" +
+				"  route (no route matched), topic \"alerts\", title \"B\""},
+		},
+		{
+			name:     "unknown endpoint",
+			endpoint: "db",
+			diffs:    []string{`unknown endpoint "db"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := testCase{
+				Endpoint:      tt.endpoint,
+				Alerts:        alerts,
+				Notifications: tt.notifications,
+			}
+			assert.Equal(t, tt.diffs, tc.run(*c))
+		})
+	}
+}
+
+func TestTestCommand(t *testing.T) {
+	a := assert.New(t)
+	config := writeFile(t, "config.yaml", `
+ntfy:
+  baseUrl: https://ntfy.sh
+  notification:
+    topic: alerts
+    title: '{{ .Labels.alertname }}'
+    description: '{{ .Annotations.summary }}'
+`)
+	tests := writeFile(t, "tests.yaml", `
+tests:
+  - name: passing
+    alerts:
+      - labels: {alertname: HighLatency}
+    notifications:
+      - title: HighLatency
+  - name: failing
+    alerts:
+      - labels: {alertname: HighLatency}
+    notifications:
+      - title: {regex: 'Low.*'}
+`)
+
+	res := run(t, "test", "--conf", config, tests)
+	a.NotZero(res.code)
+	a.Equal("--- PASS: passing\n"+
+		"--- FAIL: failing\n"+
+		"    notifications[0].title:\n"+
+		"    - /Low.*/\n"+
+		"    + \"HighLatency\"\n"+
+		"FAIL\n", res.stdout)
+	a.Contains(res.stderr, "1 of 2 test(s) failed")
+}
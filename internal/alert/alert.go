@@ -12,3 +12,96 @@ type Alert struct {
 	GeneratorURL string            `json:"generatorURL"`
 	Fingerprint  string            `json:"fingerprint"`
 }
+
+// Webhook is the payload sent by Alertmanager to the webhook. It contains a
+// group of alerts along with the data common to all of them.
+//
+// Reference: https://prometheus.io/docs/alerting/latest/configuration/#webhook_config
+type Webhook struct {
+	Version           string            `json:"version"`
+	GroupKey          string            `json:"groupKey"`
+	TruncatedAlerts   int               `json:"truncatedAlerts"`
+	Status            string            `json:"status"`
+	Receiver          string            `json:"receiver"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Alerts            Alerts            `json:"alerts"`
+}
+
+// TotalAlerts returns the number of alerts in the group, including the ones
+// Alertmanager truncated from the payload.
+func (w Webhook) TotalAlerts() int {
+	return len(w.Alerts) + w.TruncatedAlerts
+}
+
+// Alerts is a list of alerts. It provides helpers for templates and
+// expressions to filter the alerts by status.
+type Alerts []Alert
+
+// Firing returns the subset of alerts that are firing.
+func (as Alerts) Firing() Alerts {
+	return as.withStatus("firing")
+}
+
+// Resolved returns the subset of alerts that are resolved.
+func (as Alerts) Resolved() Alerts {
+	return as.withStatus("resolved")
+}
+
+func (as Alerts) withStatus(status string) Alerts {
+	var out Alerts
+	for _, a := range as {
+		if a.Status == status {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// Data is passed to templates and expressions when a notification is rendered
+// for a single alert. The fields of the alert are promoted, so `.Status` and
+// `.Labels` refer to the alert itself, while the remaining fields expose the
+// webhook payload the alert was received with.
+type Data struct {
+	Alert
+	// GroupStatus is the status of the whole group, which is "firing" as long
+	// as at least one alert in the group is firing.
+	GroupStatus       string
+	Receiver          string
+	Version           string
+	GroupKey          string
+	TruncatedAlerts   int
+	GroupLabels       map[string]string
+	CommonLabels      map[string]string
+	CommonAnnotations map[string]string
+	ExternalURL       string
+	// Alerts contains every alert of the payload, including the one being
+	// rendered.
+	Alerts Alerts
+}
+
+// NewData creates the data for rendering the provided alert, which must be
+// part of the webhook payload.
+func NewData(w Webhook, a Alert) Data {
+	return Data{
+		Alert:             a,
+		GroupStatus:       w.Status,
+		Receiver:          w.Receiver,
+		Version:           w.Version,
+		GroupKey:          w.GroupKey,
+		TruncatedAlerts:   w.TruncatedAlerts,
+		GroupLabels:       w.GroupLabels,
+		CommonLabels:      w.CommonLabels,
+		CommonAnnotations: w.CommonAnnotations,
+		ExternalURL:       w.ExternalURL,
+		Alerts:            w.Alerts,
+	}
+}
+
+// TotalAlerts returns the number of alerts in the group, including the ones
+// Alertmanager truncated from the payload.
+func (d Data) TotalAlerts() int {
+	return len(d.Alerts) + d.TruncatedAlerts
+}
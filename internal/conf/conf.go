@@ -3,6 +3,8 @@ package conf
 import (
 	"fmt"
 	"os"
+	"reflect"
+	"slices"
 	"strings"
 	"time"
 
@@ -15,8 +17,17 @@ import (
 )
 
 var (
-	defaultConfig     = "/etc/alertfy/config.yaml"
-	defaultListenAddr = ":8080"
+	defaultConfig         = "/etc/alertfy/config.yaml"
+	defaultListenAddr     = ":5748"
+	defaultPushoverRetry  = time.Minute
+	defaultPushoverExpire = time.Hour
+
+	defaultFallbackAfter    = 5 * time.Minute
+	defaultFallbackPriority = "high"
+
+	defaultNtfyTimeout     = 10 * time.Second
+	defaultBreakerFailures = 3
+	defaultBreakerOpenFor  = 30 * time.Second
 )
 
 // New creates a configuration using the provided arguments, environment
@@ -26,21 +37,82 @@ func New(args ...string) (*C, error) {
 	k := koanf.New(".")
 
 	err := k.Load(confmap.Provider(map[string]any{
-		"hook.auth.enable":              false,
-		"hook.auth.username":            "",
-		"hook.auth.password":            "",
-		"hook.log.level":                "info",
-		"hook.log.format":               "text",
-		"hook.terminationGracePeriod":   time.Second * 60,
-		"ntfy.baseUrl":                  "",
-		"ntfy.auth.enable":              false,
-		"ntfy.auth.username":            "",
-		"ntfy.auth.password":            "",
-		"ntfy.notification.topic":       StringExpr{},
-		"ntfy.notification.priority":    StringExpr{Text: "default"},
-		"ntfy.notification.tags":        []Tag{},
-		"ntfy.notification.title":       nil,
-		"ntfy.notification.description": nil,
+		"hook.auth.enable":                     false,
+		"hook.auth.username":                   "",
+		"hook.auth.password":                   "",
+		"hook.auth.credentials":                []Credential{},
+		"hook.auth.htpasswdFile":               "",
+		"hook.tls.enable":                      false,
+		"hook.tls.certFile":                    "",
+		"hook.tls.keyFile":                     "",
+		"hook.tls.clientCAFile":                "",
+		"hook.signature.enable":                false,
+		"hook.signature.secret":                "",
+		"hook.signature.header":                "X-Alertfy-Signature",
+		"hook.signature.timestampHeader":       "X-Alertfy-Timestamp",
+		"hook.signature.tolerance":             5 * time.Minute,
+		"hook.log.level":                       "info",
+		"hook.log.format":                      "text",
+		"hook.listen":                          defaultListenAddr,
+		"hook.adminListen":                     "",
+		"hook.terminationGracePeriod":          time.Second * 60,
+		"ntfy.notifier":                        NotifierNtfy,
+		"ntfy.baseUrl":                         "",
+		"ntfy.auth.enable":                     false,
+		"ntfy.auth.username":                   "",
+		"ntfy.auth.password":                   "",
+		"ntfy.servers":                         []Server{},
+		"ntfy.timeout":                         defaultNtfyTimeout,
+		"ntfy.circuitBreaker.failures":         defaultBreakerFailures,
+		"ntfy.circuitBreaker.openFor":          defaultBreakerOpenFor,
+		"ntfy.gotify.token":                    "",
+		"ntfy.pushover.token":                  "",
+		"ntfy.pushover.user":                   "",
+		"ntfy.pushover.retry":                  defaultPushoverRetry,
+		"ntfy.pushover.expire":                 defaultPushoverExpire,
+		"ntfy.webhook.url":                     "",
+		"ntfy.webhook.method":                  "POST",
+		"ntfy.webhook.headers":                 map[string]any{},
+		"ntfy.webhook.body":                    "",
+		"ntfy.email.address":                   "",
+		"ntfy.email.disableStartTLS":           false,
+		"ntfy.email.auth.enable":               false,
+		"ntfy.email.auth.username":             "",
+		"ntfy.email.auth.password":             "",
+		"ntfy.email.from":                      "",
+		"ntfy.email.to":                        []string{},
+		"ntfy.email.html":                      nil,
+		"ntfy.fallback.enable":                 false,
+		"ntfy.fallback.after":                  defaultFallbackAfter,
+		"ntfy.fallback.minPriority":            defaultFallbackPriority,
+		"ntfy.notification.mode":               ModeAlert,
+		"ntfy.notification.topic":              StringExpr{},
+		"ntfy.notification.priority":           StringExpr{Text: "default"},
+		"ntfy.notification.tags":               []Tag{},
+		"ntfy.notification.title":              nil,
+		"ntfy.notification.description":        nil,
+		"ntfy.routes":                          []Route{},
+		"delivery.queue.size":                  1024,
+		"delivery.queue.workers":               4,
+		"delivery.outbox.dir":                  "",
+		"delivery.retry.maxAttempts":           5,
+		"delivery.retry.initialBackoff":        time.Second,
+		"delivery.retry.maxBackoff":            time.Minute,
+		"delivery.retry.multiplier":            2.0,
+		"delivery.retry.jitter":                0.2,
+		"delivery.deadLetter.maxEntries":       1000,
+		"delivery.deadLetter.dir":              "",
+		"delivery.dedup.ttl":                   time.Duration(0),
+		"delivery.dedup.file":                  "",
+		"delivery.rateLimit.messagesPerMinute": 0,
+		"delivery.rateLimit.burst":             10,
+		"delivery.rateLimit.summaryInterval":   time.Minute,
+		"tracing.enable":                       false,
+		"tracing.exporter":                     ExporterOTLP,
+		"tracing.endpoint":                     "localhost:4318",
+		"tracing.insecure":                     false,
+		"tracing.serviceName":                  "alertfy",
+		"tracing.sampleRatio":                  1.0,
 	}, "."), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load default configuration: %w", err)
@@ -72,17 +144,127 @@ func New(args ...string) (*C, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal configuration: %w", err)
 	}
+	conf.keys = k.All()
+
+	conf.Ntfy.setServers()
+
+	// fill in the defaults of additional endpoints
+	for name, n := range conf.Endpoints {
+		if n.Notifier == "" {
+			n.Notifier = conf.Ntfy.Notifier
+		}
+		if n.Notifier == conf.Ntfy.Notifier {
+			if n.BaseURL == "" && len(n.Servers) == 0 {
+				n.BaseURL = conf.Ntfy.BaseURL
+				n.Auth = conf.Ntfy.Auth
+				n.Servers = conf.Ntfy.Servers
+			}
+			if n.Gotify.Token == "" {
+				n.Gotify = conf.Ntfy.Gotify
+			}
+			if n.Pushover.Token == "" {
+				n.Pushover.Token = conf.Ntfy.Pushover.Token
+			}
+			if n.Pushover.User == "" {
+				n.Pushover.User = conf.Ntfy.Pushover.User
+			}
+			if n.Webhook.URL == "" {
+				n.Webhook = conf.Ntfy.Webhook
+			}
+		}
+		if n.Email.Address == "" {
+			n.Email = conf.Ntfy.Email
+		}
+		// only fill in the options left unset, so that explicit zero values
+		// are kept as they are for the `ntfy` block
+		unset := func(key string) bool {
+			return !k.Exists("endpoints." + name + "." + key)
+		}
+		if unset("fallback.after") {
+			n.Fallback.After = defaultFallbackAfter
+		}
+		if unset("fallback.minPriority") {
+			n.Fallback.MinPriority = defaultFallbackPriority
+		}
+		n.setServers()
+		if unset("timeout") {
+			n.Timeout = defaultNtfyTimeout
+		}
+		if unset("circuitBreaker.failures") {
+			n.CircuitBreaker.Failures = defaultBreakerFailures
+		}
+		if unset("circuitBreaker.openFor") {
+			n.CircuitBreaker.OpenFor = defaultBreakerOpenFor
+		}
+		if unset("pushover.retry") {
+			n.Pushover.Retry = defaultPushoverRetry
+		}
+		if unset("pushover.expire") {
+			n.Pushover.Expire = defaultPushoverExpire
+		}
+		if unset("notification.mode") {
+			n.Notification.Mode = ModeAlert
+		}
+		conf.Endpoints[name] = n
+	}
 
 	return conf, nil
 }
 
+// Path returns the path of the config file New loads for the provided
+// arguments.
+func Path(args ...string) string {
+	path, _ := parseFlags(args).GetString("conf")
+	return path
+}
+
+// Diff returns the sorted configuration keys whose values differ between the
+// two configurations, including the keys set in only one of them. Values are
+// compared as loaded, before defaults are filled in for endpoints.
+func Diff(prev, next *C) []string {
+	var keys []string
+	for k, v := range prev.keys {
+		if nv, ok := next.keys[k]; !ok || !reflect.DeepEqual(v, nv) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range next.keys {
+		if _, ok := prev.keys[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
 func parseFlags(args []string) *flag.FlagSet {
 	f := flag.NewFlagSet("config", flag.ContinueOnError)
 	f.Usage = func() {
 		fmt.Print(f.FlagUsages())
 		os.Exit(0)
 	}
-	f.String("conf", defaultConfig, "path to config file")
+	// flags of subcommands are parsed by the subcommands themselves
+	f.ParseErrorsWhitelist.UnknownFlags = true
+	RegisterFlags(f)
 	f.Parse(args)
 	return f
 }
+
+// setServers makes BaseURL and Auth the only server if no servers are
+// listed. Otherwise, it sets them to those of the first server.
+func (n *Ntfy) setServers() {
+	if len(n.Servers) == 0 {
+		if n.BaseURL != "" {
+			n.Servers = []Server{{BaseURL: n.BaseURL, Auth: n.Auth}}
+		}
+		return
+	}
+	n.BaseURL = n.Servers[0].BaseURL
+	n.Auth = n.Servers[0].Auth
+}
+
+// RegisterFlags registers the flags understood by New, so that subcommands
+// parsing their own flags accept them as well.
+func RegisterFlags(f *flag.FlagSet) {
+	f.String("conf", defaultConfig, "path to config file")
+}
@@ -0,0 +1,94 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	a := assert.New(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	load := func(yaml string) *C {
+		require.NoError(t, os.WriteFile(path, []byte(yaml), 0o600))
+		c, err := New("--conf", path)
+		require.NoError(t, err)
+		return c
+	}
+
+	prev := load(`
+ntfy:
+  baseUrl: https://ntfy.sh
+  auth:
+    password: old
+  notification:
+    topic: alerts
+`)
+	a.Equal(path, Path("--conf", path))
+	a.Empty(Diff(prev, prev))
+
+	next := load(`
+ntfy:
+  baseUrl: https://ntfy.sh
+  auth:
+    password: new
+  notification:
+    topic: alerts
+    priority: high
+endpoints:
+  team:
+    notification:
+      topic: team
+`)
+	a.Equal([]string{
+		"endpoints.team.notification.topic",
+		"ntfy.auth.password",
+		"ntfy.notification.priority",
+	}, Diff(prev, next))
+	a.Equal(Diff(prev, next), Diff(next, prev))
+}
+
+func TestEndpointDefaults(t *testing.T) {
+	a := assert.New(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+ntfy:
+  baseUrl: https://ntfy.sh
+  fallback:
+    after: 0
+  notification:
+    topic: alerts
+endpoints:
+  unset:
+    notification:
+      topic: unset
+  zero:
+    timeout: 0
+    fallback:
+      after: 0
+    circuitBreaker:
+      openFor: 0
+    notification:
+      topic: zero
+`), 0o600))
+	c, err := New("--conf", path)
+	require.NoError(t, err)
+
+	a.Zero(c.Ntfy.Fallback.After)
+
+	unset := c.Endpoints["unset"]
+	a.Equal(defaultFallbackAfter, unset.Fallback.After)
+	a.Equal(defaultNtfyTimeout, unset.Timeout)
+	a.Equal(defaultBreakerOpenFor, unset.CircuitBreaker.OpenFor)
+	a.Equal(defaultBreakerFailures, unset.CircuitBreaker.Failures)
+	a.Equal(ModeAlert, unset.Notification.Mode)
+
+	zero := c.Endpoints["zero"]
+	a.Zero(zero.Fallback.After)
+	a.Zero(zero.Timeout)
+	a.Zero(zero.CircuitBreaker.OpenFor)
+	a.Equal(defaultBreakerFailures, zero.CircuitBreaker.Failures)
+}
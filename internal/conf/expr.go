@@ -2,6 +2,7 @@ package conf
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 	"text/template"
 	"unicode"
@@ -9,6 +10,20 @@ import (
 	"github.com/PaesslerAG/gval"
 )
 
+// language is the gval language used to parse expressions. On top of the full
+// gval language, it provides a `len` function for slices, maps and strings so
+// that expressions can reason about the number of alerts in a group.
+var language = gval.Full(gval.Function("len", func(v any) (int, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.String:
+		return rv.Len(), nil
+	case reflect.Invalid:
+		return 0, nil
+	}
+	return 0, fmt.Errorf("len: unsupported type %T", v)
+}))
+
 // Expr consists of an evaluable gval expression. It implements the
 // encoding.TextUnmarshaler interface.
 type Expr struct {
@@ -21,7 +36,7 @@ func (e *Expr) UnmarshalText(text []byte) error {
 		return nil
 	}
 	s := strings.TrimSpace(string(text))
-	ev, err := gval.Full().NewEvaluable(s)
+	ev, err := language.NewEvaluable(s)
 	if err != nil {
 		return fmt.Errorf("invalid expression %q: %w", s, err)
 	}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/murtaza-u/alertfy/internal/alert"
 	"github.com/murtaza-u/alertfy/internal/conf"
 
 	"github.com/stretchr/testify/assert"
@@ -188,3 +189,98 @@ func TestTemplate(t *testing.T) {
 		}
 	}
 }
+
+func TestAlertData(t *testing.T) {
+	w := alert.Webhook{
+		Status:          "firing",
+		Receiver:        "ntfy",
+		GroupKey:        `{}:{alertname="NodeDown"}`,
+		TruncatedAlerts: 9,
+		GroupLabels:     map[string]string{"alertname": "NodeDown"},
+		ExternalURL:     "http://alertmanager:9093",
+		Alerts: []alert.Alert{
+			{Status: "firing", Fingerprint: "a"},
+			{Status: "resolved", Fingerprint: "b"},
+			{Status: "firing", Fingerprint: "c"},
+		},
+	}
+	data := alert.NewData(w, w.Alerts[1])
+
+	exprs := []InputExpr{
+		{Typ: "string", Expr: `Status`, Output: "resolved"},
+		{Typ: "string", Expr: `GroupStatus`, Output: "firing"},
+		{Typ: "string", Expr: `GroupLabels.alertname`, Output: "NodeDown"},
+		{Typ: "int", Expr: `TotalAlerts()`, Output: 12},
+	}
+	for _, i := range exprs {
+		var expr conf.Expr
+		err := expr.UnmarshalText([]byte(i.Expr))
+		isNil := assert.Nilf(t, err, "unmarshalling expression: %s", i.Expr)
+		if !isNil {
+			continue
+		}
+
+		ctx := context.Background()
+		var out any
+
+		switch i.Typ {
+		case "string":
+			out, err = expr.Evaluable.EvalString(ctx, data)
+		case "int":
+			out, err = expr.Evaluable.EvalInt(ctx, data)
+		}
+
+		isNil = assert.Nilf(t, err, "evaluating expression: `%s`", i.Expr)
+		if isNil {
+			assert.Equalf(t, i.Output, out, "expression: `%s`", i.Expr)
+		}
+	}
+
+	var tmpl conf.Template
+	err := tmpl.UnmarshalText([]byte(
+		`{{ len .Alerts }} of {{ .TotalAlerts }} alerts in group ` +
+			`{{ .GroupLabels.alertname }} ({{ .ExternalURL }})`,
+	))
+	if assert.Nil(t, err, "unmarshalling template") {
+		buf := new(bytes.Buffer)
+		err = tmpl.Execute(buf, data)
+		if assert.Nil(t, err, "evaluating template") {
+			assert.Equal(t,
+				"3 of 12 alerts in group NodeDown (http://alertmanager:9093)",
+				buf.String())
+		}
+	}
+}
+
+func TestGroupData(t *testing.T) {
+	w := alert.Webhook{
+		Status: "firing",
+		Alerts: alert.Alerts{
+			{Status: "firing", Labels: map[string]string{"instance": "a"}},
+			{Status: "resolved", Labels: map[string]string{"instance": "b"}},
+			{Status: "firing", Labels: map[string]string{"instance": "c"}},
+		},
+	}
+
+	var expr conf.Expr
+	err := expr.UnmarshalText([]byte(`len(Alerts.Firing()) > 1 ? "high" : "default"`))
+	if assert.Nil(t, err, "unmarshalling expression") {
+		out, err := expr.Evaluable.EvalString(context.Background(), w)
+		if assert.Nil(t, err, "evaluating expression") {
+			assert.Equal(t, "high", out)
+		}
+	}
+
+	var tmpl conf.Template
+	err = tmpl.UnmarshalText([]byte(
+		`{{ range .Alerts.Firing }}{{ .Labels.instance }} {{ end }}` +
+			`| {{ range .Alerts.Resolved }}{{ .Labels.instance }}{{ end }}`,
+	))
+	if assert.Nil(t, err, "unmarshalling template") {
+		buf := new(bytes.Buffer)
+		err = tmpl.Execute(buf, w)
+		if assert.Nil(t, err, "evaluating template") {
+			assert.Equal(t, "a c | b", buf.String())
+		}
+	}
+}
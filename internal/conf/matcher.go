@@ -0,0 +1,78 @@
+package conf
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Match types of a Matcher, as in Alertmanager.
+const (
+	MatchEqual     = "="
+	MatchNotEqual  = "!="
+	MatchRegexp    = "=~"
+	MatchNotRegexp = "!~"
+)
+
+// matcherSyntax matches a label matcher such as `severity=~"critical|page"`.
+// The value may be quoted.
+var matcherSyntax = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(=~|!~|!=|=)\s*(.*?)\s*$`)
+
+// Matcher is an Alertmanager-style label matcher. It implements the
+// encoding.TextUnmarshaler interface.
+type Matcher struct {
+	Text  string
+	Name  string
+	Type  string
+	Value string
+	// re is the anchored regular expression of regexp matchers.
+	re *regexp.Regexp
+}
+
+func (m *Matcher) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	parts := matcherSyntax.FindStringSubmatch(s)
+	if parts == nil {
+		return fmt.Errorf("invalid matcher %q: expected `label=value`, "+
+			"`label!=value`, `label=~regex` or `label!~regex`", s)
+	}
+	value := parts[3]
+	if strings.HasPrefix(value, `"`) {
+		v, err := strconv.Unquote(value)
+		if err != nil {
+			return fmt.Errorf("invalid matcher %q: %w", s, err)
+		}
+		value = v
+	}
+
+	m.Text = s
+	m.Name = parts[1]
+	m.Type = parts[2]
+	m.Value = value
+	if m.Type == MatchRegexp || m.Type == MatchNotRegexp {
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid matcher %q: %w", s, err)
+		}
+		m.re = re
+	}
+	return nil
+}
+
+// Matches reports whether the labels satisfy the matcher. A missing label is
+// treated as an empty value.
+func (m Matcher) Matches(labels map[string]string) bool {
+	v := labels[m.Name]
+	switch m.Type {
+	case MatchEqual:
+		return v == m.Value
+	case MatchNotEqual:
+		return v != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(v)
+	case MatchNotRegexp:
+		return !m.re.MatchString(v)
+	}
+	return false
+}
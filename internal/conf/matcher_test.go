@@ -0,0 +1,43 @@
+package conf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatcher(t *testing.T) {
+	type input struct {
+		matcher string
+		labels  map[string]string
+		matches bool
+	}
+	a := assert.New(t)
+	labels := map[string]string{"team": "db", "severity": "critical"}
+	inputs := []input{
+		{matcher: `team="db"`, labels: labels, matches: true},
+		{matcher: `team=db`, labels: labels, matches: true},
+		{matcher: ` team = "db" `, labels: labels, matches: true},
+		{matcher: `team="web"`, labels: labels, matches: false},
+		{matcher: `team!="web"`, labels: labels, matches: true},
+		{matcher: `team!=db`, labels: labels, matches: false},
+		{matcher: `severity=~"critical|page"`, labels: labels, matches: true},
+		{matcher: `severity=~"crit"`, labels: labels, matches: false},
+		{matcher: `severity!~"warning|info"`, labels: labels, matches: true},
+		{matcher: `env=""`, labels: labels, matches: true},
+		{matcher: `env!=""`, labels: labels, matches: false},
+		{matcher: `env=~".*"`, labels: labels, matches: true},
+	}
+	for _, i := range inputs {
+		var m Matcher
+		if !a.NoErrorf(m.UnmarshalText([]byte(i.matcher)), "MATCHER=%s", i.matcher) {
+			continue
+		}
+		a.Equalf(i.matches, m.Matches(i.labels), "MATCHER=%s", i.matcher)
+	}
+
+	for _, invalid := range []string{`team`, `="db"`, `team=~"("`, `team="db`, `1team="db"`} {
+		var m Matcher
+		a.Errorf(m.UnmarshalText([]byte(invalid)), "MATCHER=%s", invalid)
+	}
+}
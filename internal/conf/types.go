@@ -6,17 +6,55 @@ import "time"
 type C struct {
 	// Hook contains the webhook configuration.
 	Hook Hook `koanf:"hook"`
-	// Ntfy contains the ntfy server configuration.
+	// Ntfy contains the ntfy server configuration of the default webhook
+	// endpoint, `/hook`.
 	Ntfy Ntfy `koanf:"ntfy"`
+	// Endpoints maps the names of additional webhook endpoints to their ntfy
+	// server configuration. An endpoint named "foo" is served at `/hook/foo`.
+	// If the notifier of an endpoint is empty, the notifier of the default
+	// endpoint is used. If both use the same notifier, the servers, as well
+	// as the Gotify and Pushover credentials and the webhook, of the default
+	// endpoint are used for the ones the endpoint leaves empty. The email
+	// relay of the default endpoint is used if the endpoint does not configure
+	// one. Names may only contain letters, digits, `-` and `_`.
+	Endpoints map[string]Ntfy `koanf:"endpoints"`
+	// Delivery contains the configuration for delivering notifications.
+	Delivery Delivery `koanf:"delivery"`
+	// Tracing contains the configuration for OpenTelemetry tracing.
+	Tracing Tracing `koanf:"tracing"`
+
+	// keys contains the flattened configuration keys the configuration was
+	// unmarshalled from, used to report which keys changed on reload.
+	keys map[string]any
 }
 
 // Hook contains all configuration related to the webhook.
 type Hook struct {
 	// Auth contains the configuration for securing the webhook endpoint with
-	// HTTP basic authentication.
-	Auth Auth `koanf:"auth"`
+	// HTTP basic authentication or bearer tokens.
+	Auth HookAuth `koanf:"auth"`
+	// TLS contains the configuration for serving the webhook over HTTPS.
+	TLS TLS `koanf:"tls"`
+	// Signature contains the configuration for verifying HMAC signatures of
+	// requests to the webhook endpoint.
+	Signature Signature `koanf:"signature"`
 	// Log contains the configuration for the log format and level.
 	Log Log `koanf:"log"`
+	// Listen is the address the webhook API server listens on. It is either a
+	// TCP address, such as ":5748" or "127.0.0.1:5748", or the path of a Unix
+	// socket prefixed by "unix://", such as "unix:///run/alertfy.sock".
+	//
+	// Default: ":5748"
+	Listen string `koanf:"listen"`
+	// AdminListen is the address of a separate listener serving the health
+	// check, the metrics and the admin API, using the same format as Listen.
+	// If set, the listener on Listen serves the webhook endpoint only, so that
+	// it can be exposed without exposing the operational endpoints. If empty,
+	// every endpoint is served on Listen, and the admin API rejects every
+	// request unless Auth is enabled.
+	//
+	// Default: ""
+	AdminListen string `koanf:"adminListen"`
 	// TerminationGracePeriod is the period after which the webhook must
 	// be forcefully terminated. A value of 0 implies no forceful
 	// termination.
@@ -25,16 +63,249 @@ type Hook struct {
 	TerminationGracePeriod time.Duration `koanf:"terminationGracePeriod"`
 }
 
-// Ntfy contains all configuration related to ntfy.
+// DefaultEndpoint is the name identifying the default webhook endpoint,
+// `/hook`, in logs and metrics. It cannot be used as the name of an
+// additional endpoint.
+const DefaultEndpoint = "default"
+
+// Endpoint returns the ntfy configuration of the named webhook endpoint. An
+// empty name refers to the default endpoint.
+func (c C) Endpoint(name string) (Ntfy, bool) {
+	if name == "" {
+		return c.Ntfy, true
+	}
+	n, ok := c.Endpoints[name]
+	return n, ok
+}
+
+// UnixSocketPrefix is the prefix of listen addresses referring to a Unix
+// socket.
+const UnixSocketPrefix = "unix://"
+
+// Notifiers.
+const (
+	NotifierNtfy     = "ntfy"
+	NotifierGotify   = "gotify"
+	NotifierPushover = "pushover"
+	NotifierWebhook  = "webhook"
+	NotifierEmail    = "email"
+)
+
+// Ntfy contains all configuration related to ntfy. Despite its name, it
+// configures any of the supported notifiers, which render notifications from
+// the same templates and expressions.
 type Ntfy struct {
+	// Notifier is the service notifications are published to.
+	// Possible values: "ntfy", "gotify", "pushover", "webhook", "email".
+	//
+	// Default: "ntfy"
+	Notifier string `koanf:"notifier"`
 	// BaseURL is the ntfy server's base URL. For example: https://ntfy.sh
 	//
-	// Required.
+	// For Gotify, it is the base URL of the Gotify server. For Pushover, it
+	// defaults to https://api.pushover.net if empty.
+	//
+	// Required, unless Servers is set. If Servers is set, it is the base URL
+	// of the first server.
 	BaseURL string `koanf:"baseUrl"`
 	// Auth contains the configuration for authenticating with the ntfy server.
+	// It is also used for Gotify servers behind a reverse proxy requiring
+	// basic auth and for the webhook notifier, and ignored for Pushover.
+	//
+	// If Servers is set, it is the auth of the first server.
 	Auth Auth `koanf:"auth"`
+	// Servers is an ordered list of ntfy servers. Notifications are
+	// published to the first available server, and to the next one if it
+	// responds with a 5XX or 429 status code, times out or is unreachable. If
+	// empty, BaseURL and Auth make up the only server.
+	Servers []Server `koanf:"servers"`
+	// Timeout is the timeout of each request to a ntfy server. A value of 0
+	// implies no timeout.
+	//
+	// Default: 10s
+	Timeout time.Duration `koanf:"timeout"`
+	// CircuitBreaker contains the configuration for skipping ntfy servers
+	// that keep failing.
+	CircuitBreaker CircuitBreaker `koanf:"circuitBreaker"`
+	// Gotify contains the configuration specific to Gotify.
+	Gotify Gotify `koanf:"gotify"`
+	// Pushover contains the configuration specific to Pushover.
+	Pushover Pushover `koanf:"pushover"`
+	// Webhook contains the configuration specific to the generic HTTP webhook
+	// notifier.
+	Webhook Webhook `koanf:"webhook"`
+	// Email contains the configuration of the email notifier, which is also
+	// used by the fallback.
+	Email Email `koanf:"email"`
+	// Fallback contains the configuration for sending notifications by email
+	// while the notifier is failing.
+	Fallback Fallback `koanf:"fallback"`
 	// Notification contains the configuration for notification messages.
 	Notification Notification `koanf:"notification"`
+	// Routes is a routing tree modeled on the Alertmanager route tree. Alerts
+	// are routed on their labels, or on the common labels of the group in
+	// "group" mode, and a notification is sent for every matched route. If
+	// no route matches, `Notification` is used as is.
+	Routes []Route `koanf:"routes"`
+}
+
+// Server is a ntfy server.
+type Server struct {
+	// BaseURL is the server's base URL. For example: https://ntfy.sh
+	//
+	// Required.
+	BaseURL string `koanf:"baseUrl"`
+	// Auth contains the configuration for authenticating with the server.
+	Auth Auth `koanf:"auth"`
+}
+
+// CircuitBreaker contains the configuration of the circuit breakers tracking
+// the health of ntfy servers. A server is skipped once enough consecutive
+// requests to it failed, and a single notification is sent to it from time to
+// time to probe whether it recovered. Every endpoint has its own circuit
+// breakers. Requests rejected with a 429 status code are not counted as
+// failed, as rate limiting is not an outage.
+type CircuitBreaker struct {
+	// Failures is the number of consecutive failed requests after which a
+	// server is skipped. Must be at least 1.
+	//
+	// Default: 3
+	Failures int `koanf:"failures"`
+	// OpenFor is how long a failing server is skipped before it is probed.
+	//
+	// Default: 30s
+	OpenFor time.Duration `koanf:"openFor"`
+}
+
+// Gotify contains the configuration for publishing notifications to a Gotify
+// server. Gotify has no topics, so the topic is not required and is only used
+// to key rate limits. Tags are appended to the message.
+type Gotify struct {
+	// Token is the application token messages are published with. Required
+	// if the notifier is "gotify".
+	Token string `koanf:"token"`
+}
+
+// Pushover contains the configuration for publishing notifications through
+// Pushover. Pushover has no topics, so the topic is not required and is only
+// used to key rate limits. Tags are appended to the message.
+type Pushover struct {
+	// Token is the API token of the Pushover application. Required if the
+	// notifier is "pushover".
+	Token string `koanf:"token"`
+	// User is the user or group key notifications are sent to. Required if
+	// the notifier is "pushover".
+	User string `koanf:"user"`
+	// Retry is how often Pushover resends emergency notifications, the ones
+	// with the "max" or "urgent" ntfy priority, until they are acknowledged.
+	// Must be at least 30s.
+	//
+	// Default: 1m
+	Retry time.Duration `koanf:"retry"`
+	// Expire is how long Pushover keeps resending emergency notifications.
+	// Must not exceed 3h.
+	//
+	// Default: 1h
+	Expire time.Duration `koanf:"expire"`
+}
+
+// Webhook contains the configuration for sending notifications to an
+// arbitrary HTTP endpoint, such as a chat bot or a ticket system. The method,
+// headers and body are templates evaluated against the same data as the title
+// and description. The topic is not required, and notifications sent to a
+// webhook are not rate limited.
+type Webhook struct {
+	// URL the requests are sent to. Required if the notifier is "webhook".
+	URL string `koanf:"url"`
+	// Method of the requests.
+	//
+	// Default: "POST"
+	Method *Template `koanf:"method"`
+	// Headers maps header names to their value. The Content-Type header
+	// defaults to "application/json".
+	//
+	// Default: {}
+	Headers map[string]*Template `koanf:"headers"`
+	// Body of the requests. For example:
+	// {"text": {{ printf "%q" (index .Annotations "summary") }}}
+	//
+	// Default: ""
+	Body *Template `koanf:"body"`
+}
+
+// Email contains the configuration for sending notifications by email
+// through an SMTP relay. The subject and body are the rendered title and
+// description. The topic is not required and only used to key rate limits.
+type Email struct {
+	// Address of the SMTP relay, as "host:port". Required if the notifier is
+	// "email" or the fallback is enabled.
+	Address string `koanf:"address"`
+	// DisableStartTLS allows sending email over an unencrypted connection.
+	// Otherwise, the connection must be upgraded to TLS with STARTTLS before
+	// authenticating and sending the email. It should only be set for relays
+	// on the same host.
+	//
+	// Default: false
+	DisableStartTLS bool `koanf:"disableStartTLS"`
+	// Auth contains the credentials to authenticate with the relay using
+	// SMTP AUTH PLAIN.
+	Auth Auth `koanf:"auth"`
+	// From is the sender address. Required if Address is set.
+	From string `koanf:"from"`
+	// To is the list of recipient addresses. Required if Address is set.
+	To []string `koanf:"to"`
+	// HTML is an optional template for an HTML version of the body, evaluated
+	// against the same data as the title and description. If set, the email
+	// is sent with both a plain text and an HTML part.
+	HTML *Template `koanf:"html"`
+}
+
+// Fallback contains the configuration for delivering notifications by email
+// while the notifier is unreachable.
+type Fallback struct {
+	// Enable the email fallback. The notifier must not be "email".
+	//
+	// Default: false
+	Enable bool `koanf:"enable"`
+	// After is how long the notifier must have been failing before
+	// notifications are sent by email instead. A notification is only sent by
+	// email after an attempt to deliver it through the notifier failed.
+	//
+	// Default: 5m
+	After time.Duration `koanf:"after"`
+	// MinPriority is the lowest priority of the notifications sent by email,
+	// so that only critical alerts are sent.
+	//
+	// Default: "high"
+	MinPriority string `koanf:"minPriority"`
+}
+
+// Route selects the notification profile for the alerts matching it. As in
+// Alertmanager, the child routes of a matching route are evaluated in order,
+// and evaluation stops at the first matching child unless its `Continue` is
+// set. The deepest matching routes are used.
+type Route struct {
+	// Name identifies the route in logs and in the output of the `routes`
+	// command. If empty, the route is identified by its position in the
+	// tree, such as "0.1" for the second child of the first route. Names must
+	// start with a letter and may only contain letters, digits, `-` and `_`.
+	Name string `koanf:"name"`
+	// Matchers is a list of label matchers, such as `team="db"` or
+	// `severity=~"critical|page"`. The route matches if all matchers match.
+	// A route without matchers matches every alert.
+	Matchers []Matcher `koanf:"matchers"`
+	// Continue controls whether the sibling routes following this route are
+	// evaluated if this route matches.
+	//
+	// Default: false
+	Continue bool `koanf:"continue"`
+	// Notification overrides the notification configuration of the parent
+	// route for the alerts matching this route. Unset fields are inherited
+	// from the parent, which is `Ntfy.Notification` for top-level routes.
+	// The mode cannot be overridden.
+	Notification Notification `koanf:"notification"`
+	// Routes is the list of child routes.
+	Routes []Route `koanf:"routes"`
 }
 
 // Auth contains HTTP basic authentication configuration.
@@ -55,6 +326,118 @@ type Auth struct {
 	Password string `koanf:"password"`
 }
 
+// HookAuth contains the configuration for authenticating requests to the
+// webhook and admin endpoints. A request is accepted if it presents any of the
+// configured credentials, which allows rotating credentials without downtime.
+// The name of the credential a request authenticated with is logged with the
+// request.
+type HookAuth struct {
+	// Enable authentication.
+	//
+	// Default: false
+	Enable bool `koanf:"enable"`
+	// Username is the username of a single HTTP basic auth credential, named
+	// after the username. Prefer `Credentials` for new setups.
+	Username string `koanf:"username"`
+	// Password is the password of the credential set through `Username`.
+	// Required if `Username` is set.
+	Password string `koanf:"password"`
+	// Credentials is a list of named credentials.
+	Credentials []Credential `koanf:"credentials"`
+	// HtpasswdFile is the path to an htpasswd file with bcrypt-hashed
+	// passwords, as created by `htpasswd -B`. Each user is a credential named
+	// after the username. The file is loaded again when it changes.
+	//
+	// Default: ""
+	HtpasswdFile string `koanf:"htpasswdFile"`
+}
+
+// Credential is a named credential accepted by the webhook. It is either an
+// HTTP basic auth username and password, or a bearer token as sent by the
+// `authorization` section of the Alertmanager `http_config`.
+type Credential struct {
+	// Name identifies the credential in logs. Required.
+	Name string `koanf:"name"`
+	// Username is the HTTP basic auth username.
+	Username string `koanf:"username"`
+	// Password is the HTTP basic auth password, either in plain text or as a
+	// bcrypt hash. Required if `Username` is set.
+	Password string `koanf:"password"`
+	// Token is the bearer token. Cannot be combined with `Username`.
+	Token string `koanf:"token"`
+}
+
+// Signature contains the configuration for verifying HMAC-SHA256 signatures
+// of requests to the webhook endpoint, for senders that can sign their
+// requests. Alertmanager cannot sign requests.
+//
+// The signature is the hex-encoded HMAC-SHA256 of the timestamp, a period and
+// the raw request body, keyed with the shared secret, and may be prefixed by
+// "sha256=". The timestamp is the number of seconds since the Unix epoch.
+// Requests with an invalid signature, a timestamp outside the tolerance, or a
+// signature already seen within the tolerance are rejected. A valid signature
+// authenticates the request without credentials.
+//
+// Signatures are an alternative to the credentials of Auth, not an additional
+// requirement: if Auth is enabled, enabling signatures does not make the
+// webhook endpoint stricter, as unsigned requests are still accepted with
+// credentials. Only with Auth disabled must every request be signed.
+type Signature struct {
+	// Enable signature verification. If enabled, unsigned requests to the
+	// webhook endpoint must present credentials if Auth is enabled, so that
+	// Alertmanager can keep sending alerts, and are rejected otherwise.
+	//
+	// Default: false
+	Enable bool `koanf:"enable"`
+	// Secret is the shared secret the signature is computed with. Required
+	// if `Enable` is true.
+	Secret string `koanf:"secret"`
+	// Header is the request header carrying the signature.
+	//
+	// Default: "X-Alertfy-Signature"
+	Header string `koanf:"header"`
+	// TimestampHeader is the request header carrying the timestamp.
+	//
+	// Default: "X-Alertfy-Timestamp"
+	TimestampHeader string `koanf:"timestampHeader"`
+	// Tolerance is the maximum difference between the timestamp and the time
+	// the request is received.
+	//
+	// Default: 5m
+	Tolerance time.Duration `koanf:"tolerance"`
+}
+
+// TLS contains the configuration for serving the webhook over HTTPS on
+// `hook.listen`. A separate admin listener always serves plain HTTP.
+type TLS struct {
+	// Enable TLS termination.
+	//
+	// Default: false
+	Enable bool `koanf:"enable"`
+	// CertFile is the path to the PEM-encoded server certificate, including
+	// any intermediate certificates. The certificate and key are loaded again
+	// when either file changes, so that rotated certificates are picked up
+	// without a restart. Required if `Enable` is true.
+	CertFile string `koanf:"certFile"`
+	// KeyFile is the path to the PEM-encoded private key of the server
+	// certificate. Required if `Enable` is true.
+	KeyFile string `koanf:"keyFile"`
+	// ClientCAFile is the path to the PEM-encoded CA certificates client
+	// certificates are verified against. If set, requests to the hook
+	// endpoint must present a client certificate signed by one of these CAs,
+	// as configured through `tls_config` in the Alertmanager `http_config`.
+	// The file is loaded again when it changes.
+	//
+	// Default: ""
+	ClientCAFile string `koanf:"clientCAFile"`
+}
+
+// Notification modes.
+const (
+	ModeAlert = "alert"
+	ModeGroup = "group"
+)
+
 // Log contains configuration for the webhook logger.
 type Log struct {
 	// Level is the log level.
@@ -69,19 +452,38 @@ type Log struct {
 	Format string `koanf:"format"`
 }
 
-// Notification contains configuration for notification messages.
+// Notification contains configuration for notification messages. In "alert"
+// mode, templates and expressions are evaluated against an alert.Data, which
+// exposes the alert along with the webhook payload it was received with. In
+// "group" mode, they are evaluated against the alert.Webhook payload itself.
 type Notification struct {
+	// Mode controls how many notifications are sent per webhook call.
+	// Possible values: "alert", "group".
+	//
+	// "alert" sends one notification per alert, while "group" renders the
+	// whole group of alerts once and sends a single notification.
+	//
+	// Default: "alert"
+	Mode string `koanf:"mode"`
 	// Topic can be a hardcoded string or a gval expression that evaluates to a
 	// string. For example: "alertmanager"
 	//
-	// Required.
+	// Required if the notifier is "ntfy", unless Topics is set.
 	Topic StringExpr `koanf:"topic"`
+	// Topics fans the notification out to several topics. The notification
+	// is sent to every topic whose condition evaluates to true or is empty,
+	// and each is delivered, retried and deduplicated independently. Mutually
+	// exclusive with Topic.
+	Topics []Topic `koanf:"topics"`
 	// Priority can be a hardcoded string or a gval expression that evaluates
 	// to a string.
 	// For example: Status == "firing" ? "urgent" : "default"
 	//
 	// Reference: https://docs.ntfy.sh/publish/#message-priority
 	//
+	// For Gotify, the priorities "min" to "max" map to 1, 3, 5, 8 and 10. For
+	// Pushover, they map to -2 to 2, where 2 is an emergency notification.
+	//
 	// Default: "default"
 	Priority StringExpr `koanf:"priority"`
 	// Tags to be included in the notification. Optional.
@@ -93,6 +495,16 @@ type Notification struct {
 	Description *Template `koanf:"description"`
 }
 
+// Topic represents a topic the notification is sent to.
+type Topic struct {
+	// Topic can be a hardcoded string or a gval expression that evaluates to
+	// a string. Required.
+	Topic StringExpr `koanf:"topic"`
+	// Condition is a gval expression. The notification is sent to the topic
+	// only if the condition evaluates to true or is empty.
+	Condition Expr `koanf:"condition"`
+}
+
 // Tag represents a tag to be included with the notification.
 type Tag struct {
 	// Tag can be an emoji shortcode or any contextual data.
@@ -101,3 +513,189 @@ type Tag struct {
 	// only if the condition evaluates to true or is empty.
 	Condition Expr `koanf:"condition"`
 }
+
+// Delivery contains all configuration related to delivering notifications to
+// the ntfy server.
+type Delivery struct {
+	// Queue contains the configuration for the in-process delivery queue.
+	Queue Queue `koanf:"queue"`
+	// Outbox contains the configuration for the durable on-disk outbox.
+	Outbox Outbox `koanf:"outbox"`
+	// Retry contains the configuration for retrying failed deliveries.
+	Retry Retry `koanf:"retry"`
+	// DeadLetter contains the configuration for the dead-letter store.
+	DeadLetter DeadLetter `koanf:"deadLetter"`
+	// Dedup contains the configuration for suppressing duplicate
+	// notifications.
+	Dedup Dedup `koanf:"dedup"`
+	// RateLimit contains the configuration for limiting the rate of
+	// notifications per topic.
+	RateLimit RateLimit `koanf:"rateLimit"`
+}
+
+// Queue contains configuration for the delivery queue. The webhook enqueues
+// rendered notifications and acknowledges Alertmanager right away, while a
+// pool of workers drains the queue.
+type Queue struct {
+	// Size is the maximum number of notifications waiting for delivery. The
+	// webhook responds with 503 when the queue is full, and with 413 when a
+	// single payload renders more notifications than the queue can hold.
+	//
+	// Default: 1024
+	Size int `koanf:"size"`
+	// Workers is the number of notifications delivered concurrently.
+	//
+	// Default: 4
+	Workers int `koanf:"workers"`
+}
+
+// Outbox contains configuration for the on-disk outbox. When enabled, rendered
+// notifications are written to the outbox before the webhook acknowledges
+// Alertmanager, and are removed only once the ntfy server accepts them.
+// Notifications still pending on startup are delivered again.
+type Outbox struct {
+	// Dir is the directory the outbox is stored in. It must be writable and
+	// should be backed by persistent storage. An empty value disables the
+	// outbox.
+	//
+	// Default: ""
+	Dir string `koanf:"dir"`
+}
+
+// Retry contains configuration for retrying failed deliveries. Only network
+// errors, 5xx and 429 responses from the ntfy server are retried. The delay
+// between attempts grows exponentially, unless the ntfy server requests a
+// specific delay through the Retry-After header.
+type Retry struct {
+	// MaxAttempts is the maximum number of delivery attempts, including the
+	// first one. A value of 1 disables retries.
+	//
+	// Default: 5
+	MaxAttempts int `koanf:"maxAttempts"`
+	// InitialBackoff is the delay before the first retry.
+	//
+	// Default: 1s
+	InitialBackoff time.Duration `koanf:"initialBackoff"`
+	// MaxBackoff caps the delay between attempts, including delays requested
+	// through the Retry-After header.
+	//
+	// Default: 1m
+	MaxBackoff time.Duration `koanf:"maxBackoff"`
+	// Multiplier is the factor the delay grows by after every attempt.
+	//
+	// Default: 2
+	Multiplier float64 `koanf:"multiplier"`
+	// Jitter randomizes each delay by up to the given fraction, in either
+	// direction. For example, 0.2 turns a 10s delay into 8s to 12s.
+	//
+	// Default: 0.2
+	Jitter float64 `koanf:"jitter"`
+}
+
+// DeadLetter contains configuration for the dead-letter store. Notifications
+// that fail permanently, either because they could not be rendered, were
+// rejected by the ntfy server or ran out of retries, are kept in the store
+// and can be inspected, replayed or purged through the /api/deadletters
+// endpoints.
+type DeadLetter struct {
+	// MaxEntries is the maximum number of entries kept. Once reached, the
+	// oldest entries are evicted.
+	//
+	// Default: 1000
+	MaxEntries int `koanf:"maxEntries"`
+	// Dir is the directory entries are persisted to. An empty value keeps
+	// entries in memory only.
+	//
+	// Default: ""
+	Dir string `koanf:"dir"`
+}
+
+// Dedup contains configuration for suppressing duplicate notifications, such
+// as the ones caused by Alertmanager retrying a webhook or by
+// `repeat_interval`. A notification is a duplicate if one was sent for the
+// same alert fingerprint and status within the TTL. In "group" mode, the group
+// key and the fingerprint and status of every alert in the group are compared
+// instead. Notifications that are not delivered, because they are
+// dead-lettered or held back by rate limiting, do not suppress their
+// duplicates.
+type Dedup struct {
+	// TTL is how long a sent notification suppresses its duplicates. A value
+	// of 0 disables deduplication.
+	//
+	// Default: 0
+	TTL time.Duration `koanf:"ttl"`
+	// File is the file the dedup cache is persisted to, so that it survives
+	// restarts. An empty value keeps the cache in memory only.
+	//
+	// Default: ""
+	File string `koanf:"file"`
+}
+
+// RateLimit contains configuration for limiting the number of notifications
+// sent per ntfy topic, so that an alert storm cannot exhaust the ntfy quota.
+// Each topic gets a token bucket. Notifications exceeding the limit are held
+// back and summarized in a single "N more alerts suppressed on topic X"
+// notification per topic, sent every summary interval. Topics of different
+// endpoints are limited separately. Notifications of the webhook notifier are
+// not rate limited, as summaries cannot be rendered into the requests it
+// expects.
+type RateLimit struct {
+	// MessagesPerMinute is the number of notifications sent per topic per
+	// minute. A value of 0 disables rate limiting.
+	//
+	// Default: 0
+	MessagesPerMinute int `koanf:"messagesPerMinute"`
+	// Burst is the number of notifications that can be sent to a topic at
+	// once before the rate limit kicks in.
+	//
+	// Default: 10
+	Burst int `koanf:"burst"`
+	// SummaryInterval is how often the summaries of held back notifications
+	// are sent.
+	//
+	// Default: 1m
+	SummaryInterval time.Duration `koanf:"summaryInterval"`
+}
+
+// Tracing contains configuration for OpenTelemetry tracing. Spans cover
+// receiving the webhook, rendering each notification field and publishing to
+// the ntfy server. The W3C trace context is propagated to the ntfy server.
+type Tracing struct {
+	// Enable OpenTelemetry tracing.
+	//
+	// Default: false
+	Enable bool `koanf:"enable"`
+	// Exporter is where spans are exported to.
+	// Possible values: "otlp", "stdout".
+	//
+	// "otlp" exports to an OTLP/HTTP endpoint, while "stdout" prints spans
+	// for local testing.
+	//
+	// Default: "otlp"
+	Exporter string `koanf:"exporter"`
+	// Endpoint is the host and port of the OTLP/HTTP endpoint. For example:
+	// otel-collector:4318
+	//
+	// Default: "localhost:4318"
+	Endpoint string `koanf:"endpoint"`
+	// Insecure disables TLS when connecting to the OTLP endpoint.
+	//
+	// Default: false
+	Insecure bool `koanf:"insecure"`
+	// ServiceName is the service name spans are reported under.
+	//
+	// Default: "alertfy"
+	ServiceName string `koanf:"serviceName"`
+	// SampleRatio is the fraction of traces sampled, between 0 and 1.
+	// Traces started by callers that propagate a sampling decision follow
+	// that decision instead.
+	//
+	// Default: 1
+	SampleRatio float64 `koanf:"sampleRatio"`
+}
+
+// Tracing exporters.
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
@@ -1,57 +1,324 @@
 package conf
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"net/mail"
 	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 )
 
-// Validate validates the provided configuration.
-func (c C) Validate() error {
-	// hook
-	if err := validateAuth(c.Hook.Auth); err != nil {
-		return fmt.Errorf("`hook.auth`: %w", err)
+var (
+	// endpointName matches valid names of webhook endpoints.
+	endpointName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	// routeName matches valid names of routes.
+	routeName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+)
+
+// problem is an invalid option, located by its path relative to the section
+// of the configuration being validated.
+type problem struct {
+	path string
+	err  error
+}
+
+func (p problem) Error() string {
+	if p.path == "" {
+		return p.err.Error()
+	}
+	return fmt.Sprintf("`%s`: %s", p.path, p.err)
+}
+
+func (p problem) Unwrap() error {
+	return p.err
+}
+
+// invalid returns the problem with the option at the provided path.
+func invalid(path, format string, args ...any) error {
+	return problem{path: path, err: fmt.Errorf(format, args...)}
+}
+
+// problems collects the problems found while validating a configuration.
+type problems []error
+
+// add records the error, if any, as a problem with the option at path. The
+// paths of the problems err is made of are relative to path. Joined errors
+// are recorded one by one.
+func (p *problems) add(path string, err error) {
+	if err == nil {
+		return
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			p.add(path, err)
+		}
+		return
 	}
-	if err := validateLogLevel(c.Hook.Log.Level); err != nil {
-		return fmt.Errorf("`c.hook.log.level`: %w", err)
+	if pr, ok := err.(problem); ok {
+		*p = append(*p, problem{path: joinPath(path, pr.path), err: pr.err})
+		return
 	}
-	if err := validateLogFormat(c.Hook.Log.Format); err != nil {
-		return fmt.Errorf("`c.hook.log.format`: %w", err)
+	*p = append(*p, problem{path: path, err: err})
+}
+
+// joinPath appends the relative path to the parent path.
+func joinPath(parent, path string) string {
+	switch {
+	case parent == "":
+		return path
+	case path == "":
+		return parent
+	case strings.HasPrefix(path, "["):
+		return parent + path
+	}
+	return parent + "." + path
+}
+
+// Validate validates the provided configuration. Every problem found is
+// reported along with the path of the offending option, joined into a single
+// error.
+func (c C) Validate() error {
+	var p problems
+
+	// hook
+	p.add("hook.auth", validateHookAuth(c.Hook.Auth))
+	p.add("hook.tls", validateTLS(c.Hook.TLS))
+	p.add("hook.signature", validateSignature(c.Hook.Signature))
+	p.add("hook.log.level", validateLogLevel(c.Hook.Log.Level))
+	p.add("hook.log.format", validateLogFormat(c.Hook.Log.Format))
+	p.add("hook.listen", validateListen(c.Hook.Listen))
+	if c.Hook.AdminListen != "" {
+		p.add("hook.adminListen", validateListen(c.Hook.AdminListen))
+		if c.Hook.AdminListen == c.Hook.Listen {
+			p.add("hook.adminListen", errors.New("cannot be the same as `hook.listen`"))
+		}
 	}
 	if c.Hook.TerminationGracePeriod < 0 {
-		return fmt.Errorf("`hook.terminationGracePeriod` cannot be -ve")
+		p.add("hook.terminationGracePeriod", errors.New("cannot be -ve"))
 	}
 
 	// ntfy
-	if _, err := url.Parse(c.Ntfy.BaseURL); err != nil {
-		return fmt.Errorf("invalid `ntfy.baseUrl` %q: %w", c.Ntfy.BaseURL, err)
+	p.add("ntfy", validateNtfy(c.Ntfy))
+
+	// endpoints
+	names := make([]string, 0, len(c.Endpoints))
+	for name := range c.Endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := "endpoints." + name
+		if !endpointName.MatchString(name) {
+			p.add(path, errors.New("invalid name: only letters, digits, `-` and `_` are allowed"))
+			continue
+		}
+		if name == DefaultEndpoint {
+			p.add(path, errors.New("the name is reserved for `/hook`"))
+			continue
+		}
+		p.add(path, validateNtfy(c.Endpoints[name]))
+	}
+
+	// delivery
+	if c.Delivery.Queue.Size < 1 {
+		p.add("delivery.queue.size", errors.New("must be at least 1"))
 	}
-	if err := validateAuth(c.Ntfy.Auth); err != nil {
-		return fmt.Errorf("`ntfy.auth`: %w", err)
+	if c.Delivery.Queue.Workers < 1 {
+		p.add("delivery.queue.workers", errors.New("must be at least 1"))
 	}
-	if c.Ntfy.Notification.Topic.Text == "" {
-		return fmt.Errorf("`ntfy.notification.topic` cannot be empty")
+	p.add("delivery.retry", validateRetry(c.Delivery.Retry))
+	if c.Delivery.DeadLetter.MaxEntries < 1 {
+		p.add("delivery.deadLetter.maxEntries", errors.New("must be at least 1"))
 	}
-	if c.Ntfy.Notification.Title == nil {
-		return fmt.Errorf("`ntfy.notification.title` cannot be empty")
+	if d := c.Delivery.DeadLetter.Dir; d != "" && d == c.Delivery.Outbox.Dir {
+		p.add("delivery.deadLetter.dir", errors.New("cannot be the same as `delivery.outbox.dir`"))
 	}
-	if c.Ntfy.Notification.Description == nil {
-		return fmt.Errorf("`ntfy.notification.description` cannot be empty")
+	if c.Delivery.Dedup.TTL < 0 {
+		p.add("delivery.dedup.ttl", errors.New("cannot be -ve"))
 	}
+	p.add("delivery.rateLimit", validateRateLimit(c.Delivery.RateLimit))
 
-	return nil
+	// tracing
+	p.add("tracing", validateTracing(c.Tracing))
+
+	return errors.Join(p...)
 }
 
 func validateAuth(auth Auth) error {
 	if !auth.Enable {
 		return nil
 	}
-	uname := auth.Username
-	pswd := auth.Password
-	if uname == "" {
-		return fmt.Errorf("auth is enabled but `auth.username` is not set")
+	var p problems
+	if auth.Username == "" {
+		p.add("username", errors.New("cannot be empty when auth is enabled"))
+	}
+	if auth.Password == "" {
+		p.add("password", errors.New("cannot be empty when auth is enabled"))
+	}
+	return errors.Join(p...)
+}
+
+func validateNtfy(n Ntfy) error {
+	var p problems
+	if _, err := url.Parse(n.BaseURL); err != nil {
+		p.add("baseUrl", err)
+	}
+	p.add("auth", validateAuth(n.Auth))
+	p.add("", validateNotifier(n))
+	p.add("fallback", validateFallback(n))
+	if n.Fallback.Enable {
+		p.add("email", validateEmail(n.Email))
+	}
+	p.add("notification.mode", validateMode(n.Notification.Mode))
+	if n.Notifier == NotifierNtfy && n.Notification.Topic.Text == "" &&
+		len(n.Notification.Topics) == 0 {
+		p.add("notification.topic", errors.New("cannot be empty"))
+	}
+	p.add("", validateTopics(n.Notification, "notification"))
+	if n.Notification.Title == nil {
+		p.add("notification.title", errors.New("cannot be empty"))
+	}
+	if n.Notification.Description == nil {
+		p.add("notification.description", errors.New("cannot be empty"))
+	}
+	p.add("", validateRoutes(n.Routes, "routes", make(map[string]bool)))
+	return errors.Join(p...)
+}
+
+// validateTopics validates the topics of the notification configuration
+// located at the provided path.
+func validateTopics(n Notification, path string) error {
+	var p problems
+	if n.Topic.Text != "" && len(n.Topics) != 0 {
+		p.add(path+".topics", errors.New("cannot be combined with `topic`"))
+	}
+	for i, t := range n.Topics {
+		if t.Topic.Text == "" {
+			p.add(fmt.Sprintf("%s.topics[%d].topic", path, i), errors.New("cannot be empty"))
+		}
+	}
+	return errors.Join(p...)
+}
+
+// validateRoutes validates a list of routes located at the provided path of
+// the routing tree. names collects the route names seen so far, which must be
+// unique across the tree.
+func validateRoutes(routes []Route, path string, names map[string]bool) error {
+	var problems problems
+	for i, r := range routes {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		if r.Name != "" {
+			if !routeName.MatchString(r.Name) {
+				problems.add(p+".name", fmt.Errorf("invalid name %q", r.Name))
+			} else if names[r.Name] {
+				problems.add(p+".name", fmt.Errorf("duplicate name %q", r.Name))
+			}
+			names[r.Name] = true
+		}
+		if r.Notification.Mode != "" {
+			problems.add(p+".notification.mode", errors.New("cannot be set on a route"))
+		}
+		problems.add("", validateTopics(r.Notification, p+".notification"))
+		problems.add("", validateRoutes(r.Routes, p+".routes", names))
+	}
+	return errors.Join(problems...)
+}
+
+func validateHookAuth(auth HookAuth) error {
+	if !auth.Enable {
+		return nil
+	}
+	var p problems
+	if auth.Username == "" && len(auth.Credentials) == 0 && auth.HtpasswdFile == "" {
+		p.add("", errors.New("auth is enabled but no credentials are configured"))
+	}
+	if auth.Username == "" && auth.Password != "" {
+		p.add("username", errors.New("cannot be empty when `password` is set"))
+	}
+	if auth.Username != "" && auth.Password == "" {
+		p.add("password", errors.New("cannot be empty when `username` is set"))
+	}
+	names := make(map[string]bool)
+	for i, cred := range auth.Credentials {
+		path := fmt.Sprintf("credentials[%d]", i)
+		p.add(path, validateCredential(cred))
+		if cred.Name != "" && names[cred.Name] {
+			p.add(path+".name", fmt.Errorf("duplicate name %q", cred.Name))
+		}
+		names[cred.Name] = true
+	}
+	return errors.Join(p...)
+}
+
+func validateCredential(cred Credential) error {
+	var p problems
+	if cred.Name == "" {
+		p.add("name", errors.New("cannot be empty"))
+	}
+	switch {
+	case cred.Token != "":
+		if cred.Username != "" || cred.Password != "" {
+			p.add("token", errors.New("cannot be combined with `username` and `password`"))
+		}
+	case cred.Username == "":
+		p.add("", errors.New("either `username` or `token` must be set"))
+	case cred.Password == "":
+		p.add("password", errors.New("cannot be empty when `username` is set"))
+	}
+	return errors.Join(p...)
+}
+
+func validateSignature(sig Signature) error {
+	if !sig.Enable {
+		return nil
+	}
+	var p problems
+	if sig.Secret == "" {
+		p.add("secret", errors.New("cannot be empty when signature verification is enabled"))
+	}
+	if sig.Header == "" {
+		p.add("header", errors.New("cannot be empty"))
+	}
+	if sig.TimestampHeader == "" {
+		p.add("timestampHeader", errors.New("cannot be empty"))
+	}
+	if sig.Tolerance <= 0 {
+		p.add("tolerance", errors.New("must be positive"))
+	}
+	return errors.Join(p...)
+}
+
+func validateTLS(tls TLS) error {
+	if !tls.Enable {
+		if tls.ClientCAFile != "" {
+			return invalid("clientCAFile", "is set but TLS is not enabled")
+		}
+		return nil
+	}
+	var p problems
+	if tls.CertFile == "" {
+		p.add("certFile", errors.New("cannot be empty when TLS is enabled"))
+	}
+	if tls.KeyFile == "" {
+		p.add("keyFile", errors.New("cannot be empty when TLS is enabled"))
+	}
+	return errors.Join(p...)
+}
+
+func validateListen(addr string) error {
+	if path, ok := strings.CutPrefix(addr, UnixSocketPrefix); ok {
+		if path == "" {
+			return fmt.Errorf("missing socket path in %q", addr)
+		}
+		return nil
 	}
-	if pswd == "" {
-		return fmt.Errorf("auth is enabled but `auth.password` is not set")
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return fmt.Errorf("invalid address %q: %w", addr, err)
 	}
 	return nil
 }
@@ -63,7 +330,7 @@ func validateLogLevel(level string) error {
 	case "warn":
 	case "error":
 	default:
-		return fmt.Errorf("invalid value for `hook.log.level`: %q", level)
+		return fmt.Errorf("invalid value %q", level)
 	}
 	return nil
 }
@@ -73,7 +340,176 @@ func validateLogFormat(format string) error {
 	case "text":
 	case "json":
 	default:
-		return fmt.Errorf("invalid value for `hook.log.format`: %q", format)
+		return fmt.Errorf("invalid value %q", format)
+	}
+	return nil
+}
+
+func validateNotifier(n Ntfy) error {
+	var p problems
+	switch n.Notifier {
+	case NotifierNtfy:
+		for i, srv := range n.Servers {
+			path := fmt.Sprintf("servers[%d]", i)
+			if srv.BaseURL == "" {
+				p.add(path+".baseUrl", errors.New("cannot be empty"))
+			} else if _, err := url.Parse(srv.BaseURL); err != nil {
+				p.add(path+".baseUrl", err)
+			}
+			p.add(path+".auth", validateAuth(srv.Auth))
+		}
+		if n.Timeout < 0 {
+			p.add("timeout", errors.New("cannot be negative"))
+		}
+		if n.CircuitBreaker.Failures < 1 {
+			p.add("circuitBreaker.failures", errors.New("must be at least 1"))
+		}
+		if n.CircuitBreaker.OpenFor <= 0 {
+			p.add("circuitBreaker.openFor", errors.New("must be positive"))
+		}
+	case NotifierGotify:
+		if n.BaseURL == "" {
+			p.add("baseUrl", errors.New("is required for gotify"))
+		}
+		if n.Gotify.Token == "" {
+			p.add("gotify.token", errors.New("is required for gotify"))
+		}
+	case NotifierPushover:
+		po := n.Pushover
+		if po.Token == "" {
+			p.add("pushover.token", errors.New("is required for pushover"))
+		}
+		if po.User == "" {
+			p.add("pushover.user", errors.New("is required for pushover"))
+		}
+		if po.Retry < 30*time.Second {
+			p.add("pushover.retry", errors.New("must be at least 30s"))
+		}
+		if po.Expire <= 0 || po.Expire > 3*time.Hour {
+			p.add("pushover.expire", errors.New("must be between 0 and 3h"))
+		}
+	case NotifierWebhook:
+		if n.Webhook.URL == "" {
+			p.add("webhook.url", errors.New("is required for webhook"))
+		} else if _, err := url.Parse(n.Webhook.URL); err != nil {
+			p.add("webhook.url", err)
+		}
+	case NotifierEmail:
+		p.add("email", validateEmail(n.Email))
+	default:
+		p.add("notifier", fmt.Errorf("invalid value %q", n.Notifier))
+	}
+	return errors.Join(p...)
+}
+
+func validateEmail(e Email) error {
+	var p problems
+	if e.Address == "" {
+		p.add("address", errors.New("cannot be empty"))
+	} else if _, _, err := net.SplitHostPort(e.Address); err != nil {
+		p.add("address", fmt.Errorf("invalid value %q: expected host:port", e.Address))
+	}
+	if _, err := mail.ParseAddress(e.From); err != nil {
+		p.add("from", fmt.Errorf("invalid address %q: %w", e.From, err))
+	}
+	if len(e.To) == 0 {
+		p.add("to", errors.New("cannot be empty"))
+	}
+	for i, to := range e.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			p.add(fmt.Sprintf("to[%d]", i), fmt.Errorf("invalid address %q: %w", to, err))
+		}
+	}
+	p.add("auth", validateAuth(e.Auth))
+	return errors.Join(p...)
+}
+
+// priorities are the valid ntfy priorities.
+var priorities = []string{
+	"min", "low", "default", "high", "max", "urgent", "1", "2", "3", "4", "5",
+}
+
+func validateFallback(n Ntfy) error {
+	f := n.Fallback
+	if !f.Enable {
+		return nil
+	}
+	var p problems
+	if n.Notifier == NotifierEmail {
+		p.add("enable", fmt.Errorf("cannot be true if the notifier is %q", NotifierEmail))
+	}
+	if f.After < 0 {
+		p.add("after", errors.New("cannot be negative"))
+	}
+	if !slices.Contains(priorities, f.MinPriority) {
+		p.add("minPriority", fmt.Errorf("invalid value %q", f.MinPriority))
+	}
+	return errors.Join(p...)
+}
+
+func validateMode(mode string) error {
+	switch mode {
+	case ModeAlert:
+	case ModeGroup:
+	default:
+		return fmt.Errorf("invalid value %q", mode)
 	}
 	return nil
 }
+
+func validateRetry(retry Retry) error {
+	var p problems
+	if retry.MaxAttempts < 1 {
+		p.add("maxAttempts", errors.New("must be at least 1"))
+	}
+	if retry.InitialBackoff <= 0 {
+		p.add("initialBackoff", errors.New("must be positive"))
+	}
+	if retry.MaxBackoff < retry.InitialBackoff {
+		p.add("maxBackoff", errors.New("cannot be less than `initialBackoff`"))
+	}
+	if retry.Multiplier < 1 {
+		p.add("multiplier", errors.New("must be at least 1"))
+	}
+	if retry.Jitter < 0 || retry.Jitter > 1 {
+		p.add("jitter", errors.New("must be between 0 and 1"))
+	}
+	return errors.Join(p...)
+}
+
+func validateRateLimit(rl RateLimit) error {
+	if rl.MessagesPerMinute < 0 {
+		return invalid("messagesPerMinute", "cannot be -ve")
+	}
+	if rl.MessagesPerMinute == 0 {
+		return nil
+	}
+	var p problems
+	if rl.Burst < 1 {
+		p.add("burst", errors.New("must be at least 1"))
+	}
+	if rl.SummaryInterval <= 0 {
+		p.add("summaryInterval", errors.New("must be positive"))
+	}
+	return errors.Join(p...)
+}
+
+func validateTracing(t Tracing) error {
+	if !t.Enable {
+		return nil
+	}
+	var p problems
+	switch t.Exporter {
+	case ExporterOTLP:
+		if t.Endpoint == "" {
+			p.add("endpoint", errors.New("cannot be empty"))
+		}
+	case ExporterStdout:
+	default:
+		p.add("exporter", fmt.Errorf("invalid value %q", t.Exporter))
+	}
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		p.add("sampleRatio", errors.New("must be between 0 and 1"))
+	}
+	return errors.Join(p...)
+}
@@ -2,6 +2,7 @@ package conf
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -39,6 +40,47 @@ func TestValidateAuth(t *testing.T) {
 	}
 }
 
+func TestValidateHookAuth(t *testing.T) {
+	a := assert.New(t)
+	valid := map[string]HookAuth{
+		"disabled": {},
+		"single":   {Enable: true, Username: "foo", Password: "bar"},
+		"htpasswd": {Enable: true, HtpasswdFile: ".htpasswd"},
+		"credentials": {Enable: true, Credentials: []Credential{
+			{Name: "eu", Username: "foo", Password: "bar"},
+			{Name: "us", Token: "secret"},
+		}},
+	}
+	for name, auth := range valid {
+		a.NoErrorf(validateHookAuth(auth), "INPUT=%s", name)
+	}
+
+	invalid := map[string]HookAuth{
+		"no credentials":   {Enable: true},
+		"missing password": {Enable: true, Username: "foo"},
+		"missing username": {Enable: true, Password: "bar"},
+		"unnamed": {Enable: true, Credentials: []Credential{
+			{Token: "secret"},
+		}},
+		"duplicate name": {Enable: true, Credentials: []Credential{
+			{Name: "eu", Token: "secret"},
+			{Name: "eu", Token: "another"},
+		}},
+		"token and username": {Enable: true, Credentials: []Credential{
+			{Name: "eu", Username: "foo", Password: "bar", Token: "secret"},
+		}},
+		"credential without password": {Enable: true, Credentials: []Credential{
+			{Name: "eu", Username: "foo"},
+		}},
+		"empty credential": {Enable: true, Credentials: []Credential{
+			{Name: "eu"},
+		}},
+	}
+	for name, auth := range invalid {
+		a.Errorf(validateHookAuth(auth), "INPUT=%s", name)
+	}
+}
+
 func TestValidateLogLevel(t *testing.T) {
 	a := assert.New(t)
 	inputs := map[string]bool{
@@ -79,3 +121,262 @@ func TestValidateLogFormat(t *testing.T) {
 		a.Errorf(err, "INPUT=%s", input)
 	}
 }
+
+func TestValidateMode(t *testing.T) {
+	a := assert.New(t)
+	inputs := map[string]bool{
+		"alert": true,
+		"group": true,
+		"":      false,
+		"GROUP": false,
+		"foo":   false,
+	}
+	for input, isValid := range inputs {
+		err := validateMode(input)
+		if isValid {
+			a.NoErrorf(err, "INPUT=%s", input)
+			continue
+		}
+		a.Errorf(err, "INPUT=%s", input)
+	}
+}
+
+func TestValidateNotifier(t *testing.T) {
+	a := assert.New(t)
+	ntfy := Ntfy{
+		Notifier: NotifierNtfy,
+		Servers: []Server{
+			{BaseURL: "https://ntfy.example.com"},
+			{BaseURL: "https://ntfy.sh", Auth: Auth{Enable: true, Username: "u", Password: "p"}},
+		},
+		Timeout:        10 * time.Second,
+		CircuitBreaker: CircuitBreaker{Failures: 3, OpenFor: 30 * time.Second},
+	}
+	a.NoError(validateNotifier(ntfy))
+	invalidNtfy := map[string]func(n *Ntfy){
+		"no server URL":    func(n *Ntfy) { n.Servers = []Server{{}} },
+		"server auth":      func(n *Ntfy) { n.Servers = []Server{{BaseURL: "x", Auth: Auth{Enable: true}}} },
+		"negative timeout": func(n *Ntfy) { n.Timeout = -time.Second },
+		"no failures":      func(n *Ntfy) { n.CircuitBreaker.Failures = 0 },
+		"never closes":     func(n *Ntfy) { n.CircuitBreaker.OpenFor = 0 },
+	}
+	for name, modify := range invalidNtfy {
+		n := ntfy
+		modify(&n)
+		a.Errorf(validateNotifier(n), "INPUT=%s", name)
+	}
+	a.Error(validateNotifier(Ntfy{}))
+	a.Error(validateNotifier(Ntfy{Notifier: "slack"}))
+
+	a.NoError(validateNotifier(Ntfy{
+		Notifier: NotifierGotify,
+		BaseURL:  "https://gotify.example.com",
+		Gotify:   Gotify{Token: "token"},
+	}))
+	a.Error(validateNotifier(Ntfy{
+		Notifier: NotifierGotify,
+		Gotify:   Gotify{Token: "token"},
+	}), "no base URL")
+	a.Error(validateNotifier(Ntfy{
+		Notifier: NotifierGotify,
+		BaseURL:  "https://gotify.example.com",
+	}), "no token")
+
+	a.NoError(validateNotifier(Ntfy{
+		Notifier: NotifierWebhook,
+		Webhook:  Webhook{URL: "https://bot.example.com/alerts"},
+	}))
+	a.Error(validateNotifier(Ntfy{Notifier: NotifierWebhook}), "no URL")
+	a.Error(validateNotifier(Ntfy{
+		Notifier: NotifierWebhook,
+		Webhook:  Webhook{URL: "http://[::1"},
+	}), "invalid URL")
+
+	valid := Pushover{
+		Token:  "token",
+		User:   "user",
+		Retry:  time.Minute,
+		Expire: time.Hour,
+	}
+	a.NoError(validateNotifier(Ntfy{Notifier: NotifierPushover, Pushover: valid}))
+	invalid := map[string]func(p *Pushover){
+		"no token":    func(p *Pushover) { p.Token = "" },
+		"no user":     func(p *Pushover) { p.User = "" },
+		"short retry": func(p *Pushover) { p.Retry = 10 * time.Second },
+		"long expire": func(p *Pushover) { p.Expire = 4 * time.Hour },
+	}
+	for name, modify := range invalid {
+		p := valid
+		modify(&p)
+		a.Errorf(validateNotifier(Ntfy{Notifier: NotifierPushover, Pushover: p}),
+			"INPUT=%s", name)
+	}
+}
+
+func TestValidateEmail(t *testing.T) {
+	a := assert.New(t)
+	valid := Email{
+		Address: "smtp.example.com:587",
+		From:    "Alertfy <alertfy@example.com>",
+		To:      []string{"oncall@example.com"},
+	}
+	a.NoError(validateEmail(valid))
+
+	invalid := map[string]func(e *Email){
+		"no address":      func(e *Email) { e.Address = "" },
+		"no port":         func(e *Email) { e.Address = "smtp.example.com" },
+		"no sender":       func(e *Email) { e.From = "" },
+		"no recipients":   func(e *Email) { e.To = nil },
+		"bad recipient":   func(e *Email) { e.To = []string{"oncall"} },
+		"no auth details": func(e *Email) { e.Auth.Enable = true },
+	}
+	for name, modify := range invalid {
+		e := valid
+		modify(&e)
+		a.Errorf(validateEmail(e), "INPUT=%s", name)
+	}
+}
+
+func TestValidateFallback(t *testing.T) {
+	a := assert.New(t)
+	valid := Ntfy{
+		Notifier: NotifierNtfy,
+		Fallback: Fallback{Enable: true, After: time.Minute, MinPriority: "high"},
+	}
+	a.NoError(validateFallback(valid))
+	a.NoError(validateFallback(Ntfy{Notifier: NotifierEmail}))
+
+	invalid := map[string]func(n *Ntfy){
+		"email notifier":   func(n *Ntfy) { n.Notifier = NotifierEmail },
+		"negative after":   func(n *Ntfy) { n.Fallback.After = -time.Second },
+		"unknown priority": func(n *Ntfy) { n.Fallback.MinPriority = "critical" },
+	}
+	for name, modify := range invalid {
+		n := valid
+		modify(&n)
+		a.Errorf(validateFallback(n), "INPUT=%s", name)
+	}
+}
+
+func TestValidateTopics(t *testing.T) {
+	a := assert.New(t)
+	topic := func(s string) Topic { return Topic{Topic: StringExpr{Text: s}} }
+	a.NoError(validateTopics(Notification{Topic: StringExpr{Text: "alerts"}}, "n"))
+	a.NoError(validateTopics(Notification{
+		Topics: []Topic{topic("team"), topic("sre-critical")},
+	}, "n"))
+
+	a.Error(validateTopics(Notification{
+		Topic:  StringExpr{Text: "alerts"},
+		Topics: []Topic{topic("team")},
+	}, "n"), "topic and topics")
+	a.Error(validateTopics(Notification{
+		Topics: []Topic{topic("team"), topic("")},
+	}, "n"), "empty topic")
+}
+
+func TestValidateRoutes(t *testing.T) {
+	a := assert.New(t)
+	routes := []Route{
+		{Name: "db"},
+		{Name: "db", Notification: Notification{Mode: ModeGroup}},
+		{Routes: []Route{{Name: "1st"}}},
+	}
+	err := validateRoutes(routes, "routes", make(map[string]bool))
+	a.EqualError(err, "`routes[1].name`: duplicate name \"db\"\n"+
+		"`routes[1].notification.mode`: cannot be set on a route\n"+
+		"`routes[2].routes[0].name`: invalid name \"1st\"")
+}
+
+func TestValidateSignature(t *testing.T) {
+	a := assert.New(t)
+	valid := Signature{
+		Enable:          true,
+		Secret:          "s3cr3t",
+		Header:          "X-Alertfy-Signature",
+		TimestampHeader: "X-Alertfy-Timestamp",
+		Tolerance:       5 * time.Minute,
+	}
+	a.NoError(validateSignature(valid))
+	a.NoError(validateSignature(Signature{}))
+
+	invalid := map[string]func(s *Signature){
+		"no secret":           func(s *Signature) { s.Secret = "" },
+		"no header":           func(s *Signature) { s.Header = "" },
+		"no timestamp header": func(s *Signature) { s.TimestampHeader = "" },
+		"zero tolerance":      func(s *Signature) { s.Tolerance = 0 },
+	}
+	for name, modify := range invalid {
+		s := valid
+		modify(&s)
+		a.Errorf(validateSignature(s), "INPUT=%s", name)
+	}
+}
+
+func TestValidateTLS(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(validateTLS(TLS{}))
+	a.NoError(validateTLS(TLS{
+		Enable:   true,
+		CertFile: "tls.crt",
+		KeyFile:  "tls.key",
+	}))
+	a.NoError(validateTLS(TLS{
+		Enable:       true,
+		CertFile:     "tls.crt",
+		KeyFile:      "tls.key",
+		ClientCAFile: "ca.crt",
+	}))
+	a.Error(validateTLS(TLS{Enable: true, KeyFile: "tls.key"}))
+	a.Error(validateTLS(TLS{Enable: true, CertFile: "tls.crt"}))
+	a.Error(validateTLS(TLS{ClientCAFile: "ca.crt"}))
+}
+
+func TestValidateListen(t *testing.T) {
+	a := assert.New(t)
+	inputs := map[string]bool{
+		":5748":                    true,
+		"127.0.0.1:5748":           true,
+		"[::1]:5748":               true,
+		"unix:///run/alertfy.sock": true,
+		"unix://alertfy.sock":      true,
+		"unix://":                  false,
+		"":                         false,
+		"5748":                     false,
+		"localhost":                false,
+	}
+	for input, isValid := range inputs {
+		err := validateListen(input)
+		if isValid {
+			a.NoErrorf(err, "INPUT=%s", input)
+			continue
+		}
+		a.Errorf(err, "INPUT=%s", input)
+	}
+}
+
+func TestValidateRetry(t *testing.T) {
+	a := assert.New(t)
+	valid := Retry{
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+	a.NoError(validateRetry(valid))
+
+	invalid := map[string]func(r *Retry){
+		"zero attempts":      func(r *Retry) { r.MaxAttempts = 0 },
+		"zero backoff":       func(r *Retry) { r.InitialBackoff = 0 },
+		"max below initial":  func(r *Retry) { r.MaxBackoff = time.Millisecond },
+		"shrinking backoff":  func(r *Retry) { r.Multiplier = 0.5 },
+		"negative jitter":    func(r *Retry) { r.Jitter = -0.1 },
+		"jitter exceeding 1": func(r *Retry) { r.Jitter = 1.5 },
+	}
+	for name, modify := range invalid {
+		r := valid
+		modify(&r)
+		a.Errorf(validateRetry(r), "INPUT=%s", name)
+	}
+}
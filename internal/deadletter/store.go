@@ -0,0 +1,204 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/murtaza-u/alertfy/internal/alert"
+	"github.com/murtaza-u/alertfy/internal/metrics"
+	"github.com/murtaza-u/alertfy/internal/ntfy"
+	"github.com/murtaza-u/alertfy/internal/outbox"
+)
+
+// ErrNotFound is returned when an entry does not exist in the store.
+var ErrNotFound = errors.New("dead letter not found")
+
+// Entry is a notification that failed permanently.
+type Entry struct {
+	// ID uniquely identifies the entry.
+	ID string `json:"id"`
+	// CreatedAt is when the entry was added to the store.
+	CreatedAt time.Time `json:"createdAt"`
+	// Endpoint is the name of the webhook endpoint the notification was
+	// received on. Empty for the default endpoint.
+	Endpoint string `json:"endpoint,omitempty"`
+	// Route is the ID of the route the notification was rendered for. Empty
+	// if the alert did not match any route.
+	Route string `json:"route,omitempty"`
+	// Fingerprint is the fingerprint of the alert the notification was
+	// rendered for. Empty for notifications rendered for a whole group.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// GroupKey identifies the group of alerts the notification was rendered
+	// for.
+	GroupKey string `json:"groupKey"`
+	// Payload is the webhook payload the notification was rendered from,
+	// unchanged, so that a notification rendered for a single alert, found
+	// by its Fingerprint, renders the same when replayed.
+	Payload alert.Webhook `json:"payload"`
+	// Notification is the rendered notification. Nil if rendering failed.
+	Notification *ntfy.Data `json:"notification,omitempty"`
+	// Error describes why the notification failed.
+	Error string `json:"error"`
+	// Attempts is the history of delivery attempts, if any were made.
+	Attempts []Attempt `json:"attempts,omitempty"`
+}
+
+// Attempt is a single failed delivery attempt.
+type Attempt struct {
+	// At is when the attempt was made.
+	At time.Time `json:"at"`
+	// Error describes why the attempt failed.
+	Error string `json:"error"`
+}
+
+// Store keeps dead letters in memory, optionally backed by an on-disk store so
+// that they survive restarts. Once the store is full, the oldest entries are
+// evicted.
+type Store struct {
+	mu      sync.RWMutex
+	entries map[string]Entry
+	size    int
+	disk    *outbox.Outbox
+}
+
+// New creates a dead-letter store holding at most size entries. If dir is not
+// empty, entries are persisted to and loaded from that directory.
+func New(size int, dir string) (*Store, error) {
+	s := &Store{
+		entries: make(map[string]Entry),
+		size:    size,
+	}
+	if dir == "" {
+		return s, nil
+	}
+
+	disk, err := outbox.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	records, err := disk.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		var e Entry
+		if err := json.Unmarshal(r.Data, &e); err != nil {
+			return nil, fmt.Errorf("decoding dead letter %q: %w", r.ID, err)
+		}
+		e.ID = r.ID
+		s.entries[e.ID] = e
+	}
+	s.disk = disk
+	s.evict()
+	metrics.DeadLetters.Set(float64(len(s.entries)))
+	return s, nil
+}
+
+// Add stores the provided entry, assigning it an ID and creation time. It
+// returns the stored entry.
+func (s *Store) Add(e Entry) (Entry, error) {
+	e.ID = outbox.NewID()
+	e.CreatedAt = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.disk != nil {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return Entry{}, fmt.Errorf("encoding dead letter: %w", err)
+		}
+		if err := s.disk.Put(e.ID, data); err != nil {
+			return Entry{}, err
+		}
+	}
+	s.entries[e.ID] = e
+	s.evict()
+	metrics.DeadLetters.Set(float64(len(s.entries)))
+	return e, nil
+}
+
+// Get returns the entry with the provided ID.
+func (s *Store) Get(id string) (Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.entries[id]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	return e, nil
+}
+
+// List returns all entries, oldest first.
+func (s *Store) List() []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sorted()
+}
+
+// Delete removes the entry with the provided ID.
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[id]; !ok {
+		return ErrNotFound
+	}
+	return s.remove(id)
+}
+
+// Purge removes all entries. It returns the number of removed entries.
+func (s *Store) Purge() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id := range s.entries {
+		if err := s.remove(id); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Len returns the number of entries in the store.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
+
+func (s *Store) remove(id string) error {
+	if s.disk != nil {
+		if err := s.disk.Delete(id); err != nil {
+			return err
+		}
+	}
+	delete(s.entries, id)
+	metrics.DeadLetters.Set(float64(len(s.entries)))
+	return nil
+}
+
+// evict removes the oldest entries until the store holds at most size entries.
+func (s *Store) evict() {
+	if len(s.entries) <= s.size {
+		return
+	}
+	for _, e := range s.sorted()[:len(s.entries)-s.size] {
+		s.remove(e.ID)
+	}
+}
+
+// sorted returns the entries ordered by ID, which is their insertion order.
+func (s *Store) sorted() []Entry {
+	entries := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+	return entries
+}
@@ -0,0 +1,51 @@
+package deadletter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+
+	s, err := New(2, dir)
+	if !a.NoError(err) {
+		return
+	}
+	var ids []string
+	for _, fp := range []string{"a", "b", "c"} {
+		e, err := s.Add(Entry{Fingerprint: fp, Error: "failed"})
+		a.NoError(err)
+		ids = append(ids, e.ID)
+	}
+
+	// the oldest entry is evicted once the store is full
+	_, err = s.Get(ids[0])
+	a.ErrorIs(err, ErrNotFound)
+	a.Equal(2, s.Len())
+
+	// entries survive reopening the store
+	s, err = New(2, dir)
+	if !a.NoError(err) {
+		return
+	}
+	entries := s.List()
+	if a.Len(entries, 2) {
+		a.Equal("b", entries[0].Fingerprint)
+		a.Equal("c", entries[1].Fingerprint)
+	}
+
+	a.NoError(s.Delete(ids[1]))
+	a.ErrorIs(s.Delete(ids[1]), ErrNotFound)
+
+	n, err := s.Purge()
+	a.NoError(err)
+	a.Equal(1, n)
+
+	s, err = New(2, dir)
+	if a.NoError(err) {
+		a.Equal(0, s.Len())
+	}
+}
@@ -0,0 +1,200 @@
+package dedup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// maxJanitorInterval caps how often expired entries are pruned and, if
+// persistence is enabled, the cache is flushed to disk.
+const maxJanitorInterval = time.Minute
+
+// Key identifies a notification for deduplication. Notifications sharing the
+// same ID are duplicates only if their State is identical as well, so that a
+// change in state, such as an alert resolving, is never suppressed.
+type Key struct {
+	ID    string `json:"id"`
+	State string `json:"state"`
+}
+
+type entry struct {
+	State string    `json:"state"`
+	At    time.Time `json:"at"`
+}
+
+// Cache remembers the notifications sent within a time window. It can
+// optionally be persisted to a file, in which case it is flushed to disk
+// periodically and on Close. A nil *Cache never suppresses anything.
+type Cache struct {
+	ttl  time.Duration
+	file string
+
+	mu      sync.Mutex
+	entries map[string]entry
+	dirty   bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates a cache that suppresses duplicate notifications for the
+// provided TTL. If file is not empty, the cache is loaded from and persisted
+// to that file.
+func New(ttl time.Duration, file string) (*Cache, error) {
+	c := &Cache{
+		ttl:     ttl,
+		file:    file,
+		entries: make(map[string]entry),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if file != "" {
+		if err := c.load(); err != nil {
+			return nil, err
+		}
+	}
+	go c.janitor(min(ttl, maxJanitorInterval))
+	return c, nil
+}
+
+// Seen reports whether an identical notification was sent within the TTL,
+// along with how long ago it was sent. If it was not, the notification is
+// recorded as sent now.
+func (c *Cache) Seen(k Key) (time.Duration, bool) {
+	if c == nil {
+		return 0, false
+	}
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[k.ID]; ok && e.State == k.State {
+		if age := now.Sub(e.At); age < c.ttl {
+			return age, true
+		}
+	}
+	c.entries[k.ID] = entry{State: k.State, At: now}
+	c.dirty = true
+	return 0, false
+}
+
+// Forget removes a notification recorded by Seen, for instance because it
+// could not be enqueued after all.
+func (c *Cache) Forget(k Key) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[k.ID]; ok && e.State == k.State {
+		delete(c.entries, k.ID)
+		c.dirty = true
+	}
+}
+
+// Close stops the background pruning and flushes the cache to disk, if
+// persistence is enabled.
+func (c *Cache) Close() error {
+	if c == nil {
+		return nil
+	}
+	close(c.stop)
+	<-c.done
+	return c.flush()
+}
+
+func (c *Cache) janitor(interval time.Duration) {
+	defer close(c.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-t.C:
+			c.prune()
+			if err := c.flush(); err != nil {
+				slog.LogAttrs(
+					context.Background(),
+					slog.LevelError,
+					"failed to persist dedup cache",
+					slog.String("error", err.Error()),
+				)
+			}
+		}
+	}
+}
+
+// prune removes the entries older than the TTL.
+func (c *Cache) prune() {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, e := range c.entries {
+		if now.Sub(e.At) >= c.ttl {
+			delete(c.entries, id)
+			c.dirty = true
+		}
+	}
+}
+
+// flush atomically writes the cache to disk, if persistence is enabled and
+// the cache changed since the last flush.
+func (c *Cache) flush() error {
+	if c.file == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(c.entries)
+	c.dirty = false
+	c.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("encoding dedup cache: %w", err)
+	}
+
+	tmp := c.file + ".tmp"
+	err = os.WriteFile(tmp, data, 0o600)
+	if err != nil {
+		err = fmt.Errorf("writing dedup cache: %w", err)
+	} else if err = os.Rename(tmp, c.file); err != nil {
+		err = fmt.Errorf("committing dedup cache: %w", err)
+	}
+	if err != nil {
+		// try again on the next flush
+		c.mu.Lock()
+		c.dirty = true
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// load reads the cache from disk. A missing file yields an empty cache.
+func (c *Cache) load() error {
+	if err := os.MkdirAll(filepath.Dir(c.file), 0o700); err != nil {
+		return fmt.Errorf("creating dedup cache directory: %w", err)
+	}
+	data, err := os.ReadFile(c.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading dedup cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return fmt.Errorf("decoding dedup cache: %w", err)
+	}
+	c.prune()
+	return nil
+}
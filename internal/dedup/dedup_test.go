@@ -0,0 +1,64 @@
+package dedup
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	a := assert.New(t)
+	file := filepath.Join(t.TempDir(), "dedup", "cache.json")
+
+	c, err := New(time.Hour, file)
+	if !a.NoError(err) {
+		return
+	}
+	firing := Key{ID: "a", State: "firing"}
+	resolved := Key{ID: "a", State: "resolved"}
+
+	_, seen := c.Seen(firing)
+	a.False(seen, "first notification")
+	_, seen = c.Seen(firing)
+	a.True(seen, "duplicate notification")
+	_, seen = c.Seen(Key{ID: "b", State: "firing"})
+	a.False(seen, "other alert")
+
+	// a change in state is never suppressed, and resets the window
+	_, seen = c.Seen(resolved)
+	a.False(seen, "resolved notification")
+	_, seen = c.Seen(firing)
+	a.False(seen, "firing again after resolving")
+
+	c.Forget(firing)
+	_, seen = c.Seen(firing)
+	a.False(seen, "forgotten notification")
+
+	// the cache survives a restart
+	a.NoError(c.Close())
+	c, err = New(time.Hour, file)
+	if !a.NoError(err) {
+		return
+	}
+	_, seen = c.Seen(firing)
+	a.True(seen, "duplicate after restart")
+	a.NoError(c.Close())
+
+	// expired entries do not suppress
+	c, err = New(time.Millisecond, "")
+	if !a.NoError(err) {
+		return
+	}
+	c.Seen(firing)
+	time.Sleep(5 * time.Millisecond)
+	_, seen = c.Seen(firing)
+	a.False(seen, "expired notification")
+	a.NoError(c.Close())
+
+	var disabled *Cache
+	disabled.Seen(firing)
+	_, seen = disabled.Seen(firing)
+	a.False(seen, "nil cache")
+}
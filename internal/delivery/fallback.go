@@ -0,0 +1,63 @@
+package delivery
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/notify"
+	"github.com/murtaza-u/alertfy/internal/ntfy"
+)
+
+// fallback delivers the notifications of an endpoint by email while its
+// notifier has been failing for longer than the configured threshold.
+type fallback struct {
+	conf     conf.Fallback
+	notifier notify.Notifier
+	now      func() time.Time
+
+	// mu guards failingSince, which is the time of the first failed attempt
+	// since the notifier last succeeded. Zero if the last attempt succeeded.
+	mu           sync.Mutex
+	failingSince time.Time
+}
+
+func newFallback(c conf.Ntfy) *fallback {
+	return &fallback{
+		conf:     c.Fallback,
+		notifier: notify.NewEmail(c.Email),
+		now:      time.Now,
+	}
+}
+
+// record records the outcome of an attempt at delivering a notification
+// through the notifier.
+func (f *fallback) record(ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case ok:
+		f.failingSince = time.Time{}
+	case f.failingSince.IsZero():
+		f.failingSince = f.now()
+	}
+}
+
+// applies reports whether the notification is to be sent by email, which is
+// the case if its priority is high enough and the notifier has been failing
+// for long enough.
+func (f *fallback) applies(n ntfy.Data) bool {
+	if ntfy.PriorityLevel(n.Priority) < ntfy.PriorityLevel(f.conf.MinPriority) {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return !f.failingSince.IsZero() && f.now().Sub(f.failingSince) >= f.conf.After
+}
+
+// notify sends the notification by email. It returns the address of the SMTP
+// relay that accepted it.
+func (f *fallback) notify(ctx context.Context, n ntfy.Data) (string, *notify.Error) {
+	return f.notifier.Notify(ctx, n)
+}
@@ -0,0 +1,73 @@
+package delivery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/notify"
+	"github.com/murtaza-u/alertfy/internal/ntfy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is a notifier recording the notifications it is asked to send.
+type recorder struct {
+	sent []ntfy.Data
+}
+
+func (r *recorder) Notify(_ context.Context, n ntfy.Data) (string, *notify.Error) {
+	r.sent = append(r.sent, n)
+	return "smtp.example.com:587", nil
+}
+
+func TestFallback(t *testing.T) {
+	a := assert.New(t)
+	status := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		},
+	))
+	defer srv.Close()
+
+	n, err := notify.New(conf.DefaultEndpoint, conf.Ntfy{
+		Notifier: conf.NotifierNtfy,
+		BaseURL:  srv.URL,
+	}, srv.Client())
+	require.NoError(t, err)
+	email := new(recorder)
+	now := time.Now()
+	fb := &fallback{
+		conf:     conf.Fallback{Enable: true, After: time.Minute, MinPriority: "high"},
+		notifier: email,
+		now:      func() time.Time { return now },
+	}
+	q := &Queue{
+		notifiers: map[string]notify.Notifier{"": n},
+		fallbacks: map[string]*fallback{"": fb},
+		retry:     conf.Retry{MaxAttempts: 1},
+	}
+	deliver := func(priority string) error {
+		_, err := q.deliver(context.Background(), Job{
+			Notification: ntfy.Data{Topic: "topic", Priority: priority},
+		})
+		return err
+	}
+
+	a.Error(deliver("urgent"), "ntfy has not been failing for long enough")
+	now = now.Add(time.Minute)
+	a.NoError(deliver("urgent"))
+	a.Error(deliver("default"), "priority is too low")
+	a.Len(email.sent, 1)
+
+	status = http.StatusOK
+	a.NoError(deliver("urgent"))
+	status = http.StatusServiceUnavailable
+	a.Error(deliver("urgent"), "ntfy recovered in the meantime")
+	a.Len(email.sent, 1)
+}
@@ -0,0 +1,70 @@
+package delivery
+
+import (
+	"log/slog"
+
+	"github.com/murtaza-u/alertfy/internal/alert"
+	"github.com/murtaza-u/alertfy/internal/deadletter"
+	"github.com/murtaza-u/alertfy/internal/dedup"
+	"github.com/murtaza-u/alertfy/internal/ntfy"
+)
+
+// Job is a rendered notification waiting to be delivered.
+type Job struct {
+	// ID uniquely identifies the job in the outbox.
+	ID string `json:"id"`
+	// Endpoint is the name of the webhook endpoint the notification was
+	// received on. Empty for the default endpoint.
+	Endpoint string `json:"endpoint,omitempty"`
+	// Route is the ID of the route the notification was rendered for. Empty
+	// if the alert did not match any route.
+	Route string `json:"route,omitempty"`
+	// Fingerprint is the fingerprint of the alert the notification was
+	// rendered for. Empty for notifications rendered for a whole group.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// GroupKey identifies the group of alerts the notification was rendered
+	// for.
+	GroupKey string `json:"groupKey"`
+	// Payload is the webhook payload the notification was rendered from,
+	// unchanged, so that a notification rendered for a single alert, found
+	// by its Fingerprint, renders the same when replayed.
+	Payload alert.Webhook `json:"payload"`
+	// Notification is the rendered notification.
+	Notification ntfy.Data `json:"notification"`
+	// Dedup identifies the notification for deduplication. It is forgotten
+	// if the notification is not delivered, so that it is not suppressed when
+	// Alertmanager sends it again.
+	Dedup dedup.Key `json:"dedup"`
+	// Summary reports whether the notification summarizes the notifications
+	// held back by rate limiting. Summaries are not rate limited themselves.
+	Summary bool `json:"summary,omitempty"`
+	// TraceContext carries the trace context of the webhook request the job
+	// was created by, so that its delivery is part of the same trace.
+	TraceContext map[string]string `json:"traceContext,omitempty"`
+}
+
+// LogAttr returns the log attribute identifying the job.
+func (j Job) LogAttr() slog.Attr {
+	if j.Summary {
+		return slog.String("summary", j.Notification.Topic)
+	}
+	if j.Fingerprint != "" {
+		return slog.String("fingerprint", j.Fingerprint)
+	}
+	return slog.String("groupKey", j.GroupKey)
+}
+
+// DeadLetter returns the dead-letter entry recording the job's failure.
+func (j Job) DeadLetter(err error, attempts []deadletter.Attempt) deadletter.Entry {
+	n := j.Notification
+	return deadletter.Entry{
+		Endpoint:     j.Endpoint,
+		Route:        j.Route,
+		Fingerprint:  j.Fingerprint,
+		GroupKey:     j.GroupKey,
+		Payload:      j.Payload,
+		Notification: &n,
+		Error:        err.Error(),
+		Attempts:     attempts,
+	}
+}
@@ -0,0 +1,134 @@
+package delivery
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/murtaza-u/alertfy/internal/metrics"
+	"github.com/murtaza-u/alertfy/internal/ntfy"
+)
+
+// overflow tracks the notifications held back on a topic by rate limiting.
+type overflow struct {
+	endpoint string
+	url      string
+	topic    string
+	count    int
+	priority string
+}
+
+// allow reports whether the job's notification may be sent without exceeding
+// the rate limit of its topic. If it may not, the notification is accounted
+// for in the topic's overflow summary instead. Topics are identified by their
+// endpoint and name, as endpoints may publish to different servers.
+//
+// Notifications of the webhook notifier are not rate limited, as the summary
+// cannot be rendered into the request the webhook expects.
+func (q *Queue) allow(j Job) bool {
+	if q.limiter == nil || j.Summary || j.Notification.Webhook != nil {
+		return true
+	}
+	topic := j.Notification.Topic
+	key := limitKey(j.Endpoint, topic)
+	if q.limiter.Allow(key) {
+		return true
+	}
+
+	q.overflowMu.Lock()
+	o, ok := q.overflows[key]
+	if !ok {
+		o = &overflow{
+			endpoint: j.Endpoint,
+			url:      j.Notification.URL,
+			topic:    topic,
+			priority: j.Notification.Priority,
+		}
+		q.overflows[key] = o
+	}
+	o.count++
+	metrics.NotificationsSuppressed.WithLabelValues("rate_limit").Inc()
+	if ntfy.PriorityLevel(j.Notification.Priority) > ntfy.PriorityLevel(o.priority) {
+		o.priority = j.Notification.Priority
+	}
+	q.overflowMu.Unlock()
+
+	slog.LogAttrs(
+		q.ctx,
+		slog.LevelDebug,
+		"rate limit exceeded. Holding notification back for summary",
+		j.LogAttr(),
+		slog.String("topic", topic),
+	)
+	return false
+}
+
+// limitKey returns the key identifying a topic of an endpoint in the rate
+// limiter.
+func limitKey(endpoint, topic string) string {
+	return endpoint + "/" + topic
+}
+
+// summarize periodically enqueues a summary for every topic notifications
+// were held back on, until the queue is shut down.
+func (q *Queue) summarize(interval time.Duration) {
+	defer close(q.summaryDone)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-q.summaryStop:
+			return
+		case <-t.C:
+			q.flushOverflows()
+			q.limiter.Prune()
+		}
+	}
+}
+
+// flushOverflows enqueues a summary notification for every topic
+// notifications were held back on since the last flush. Summaries are not
+// subject to rate limiting.
+func (q *Queue) flushOverflows() {
+	q.overflowMu.Lock()
+	overflows := q.overflows
+	q.overflows = make(map[string]*overflow)
+	q.overflowMu.Unlock()
+
+	for _, o := range overflows {
+		topic := o.topic
+		j := Job{
+			Endpoint: o.endpoint,
+			Summary:  true,
+			Notification: ntfy.Data{
+				URL:   o.url,
+				Topic: topic,
+				Title: fmt.Sprintf("%d more alerts suppressed", o.count),
+				Description: fmt.Sprintf(
+					"%d more alerts suppressed on topic %s due to rate limiting",
+					o.count, topic,
+				),
+				Priority: o.priority,
+				Tags:     "warning",
+			},
+		}
+		if err := q.Enqueue(j); err != nil {
+			slog.LogAttrs(
+				q.ctx,
+				slog.LevelError,
+				"failed to enqueue rate limit summary",
+				slog.String("topic", topic),
+				slog.Int("suppressed", o.count),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		slog.LogAttrs(
+			q.ctx,
+			slog.LevelInfo,
+			"notifications suppressed by rate limit",
+			slog.String("topic", topic),
+			slog.Int("suppressed", o.count),
+		)
+	}
+}
@@ -0,0 +1,116 @@
+package delivery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/deadletter"
+	"github.com/murtaza-u/alertfy/internal/ntfy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rateLimited returns a configuration allowing a single notification per
+// topic.
+func rateLimited(workers int) conf.C {
+	return conf.C{
+		Ntfy: conf.Ntfy{Notifier: conf.NotifierNtfy, BaseURL: "http://127.0.0.1:0"},
+		Delivery: conf.Delivery{
+			Queue: conf.Queue{Size: 10, Workers: workers},
+			Retry: conf.Retry{MaxAttempts: 1},
+			RateLimit: conf.RateLimit{
+				MessagesPerMinute: 1,
+				Burst:             1,
+				SummaryInterval:   time.Hour,
+			},
+		},
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	a := assert.New(t)
+	dl, err := deadletter.New(10, "")
+	require.NoError(t, err)
+	// without workers, nothing is drained from the queue
+	q, err := NewQueue(rateLimited(0), dl, nil)
+	require.NoError(t, err)
+	defer q.Shutdown(context.Background())
+
+	job := func(endpoint, topic string) Job {
+		return Job{
+			Endpoint: endpoint,
+			Notification: ntfy.Data{
+				URL:   "https://ntfy.example.com/" + topic,
+				Topic: topic,
+			},
+		}
+	}
+	a.True(q.allow(job("", "alerts")))
+	a.False(q.allow(job("", "alerts")))
+	a.True(q.allow(job("", "db")), "topics are limited separately")
+	a.True(q.allow(job("team", "alerts")), "endpoints are limited separately")
+	a.False(q.allow(job("team", "alerts")))
+
+	q.flushOverflows()
+	a.Equal(2, q.Len())
+	for range 2 {
+		j := <-q.jobs
+		a.True(j.Summary)
+		a.Equal("https://ntfy.example.com/alerts", j.Notification.URL)
+		a.Contains([]string{"", "team"}, j.Endpoint)
+	}
+}
+
+func TestRateLimitWebhook(t *testing.T) {
+	a := assert.New(t)
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+		},
+	))
+	defer srv.Close()
+
+	c := rateLimited(1)
+	c.Endpoints = map[string]conf.Ntfy{
+		"hook": {
+			Notifier: conf.NotifierWebhook,
+			Webhook:  conf.Webhook{URL: srv.URL},
+		},
+	}
+	dl, err := deadletter.New(10, "")
+	require.NoError(t, err)
+	q, err := NewQueue(c, dl, nil)
+	require.NoError(t, err)
+
+	job := Job{
+		Endpoint: "hook",
+		Notification: ntfy.Data{
+			URL:     srv.URL,
+			Topic:   "alerts",
+			Webhook: &ntfy.Webhook{Method: http.MethodPost, Body: "{}"},
+		},
+	}
+	require.NoError(t, q.Enqueue(job, job, job))
+	a.NoError(q.Shutdown(context.Background()))
+	a.EqualValues(3, hits.Load(), "webhook notifications are not rate limited")
+	a.Empty(dl.List())
+}
+
+func TestShutdownTwice(t *testing.T) {
+	dl, err := deadletter.New(10, "")
+	require.NoError(t, err)
+	q, err := NewQueue(rateLimited(1), dl, nil)
+	require.NoError(t, err)
+
+	assert.NoError(t, q.Shutdown(context.Background()))
+	assert.NotPanics(t, func() {
+		assert.NoError(t, q.Shutdown(context.Background()))
+	})
+}
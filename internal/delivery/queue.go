@@ -0,0 +1,479 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/deadletter"
+	"github.com/murtaza-u/alertfy/internal/dedup"
+	"github.com/murtaza-u/alertfy/internal/metrics"
+	"github.com/murtaza-u/alertfy/internal/notify"
+	"github.com/murtaza-u/alertfy/internal/outbox"
+	"github.com/murtaza-u/alertfy/internal/ratelimit"
+	"github.com/murtaza-u/alertfy/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ErrQueueFull is returned when the queue does not have enough room for
+	// the jobs being enqueued.
+	ErrQueueFull = errors.New("delivery queue is full")
+	// ErrBatchTooLarge is returned when more jobs are enqueued at once than
+	// the queue can hold, so that they could never be enqueued.
+	ErrBatchTooLarge = errors.New("batch of notifications exceeds the size of the delivery queue")
+	// ErrQueueClosed is returned when jobs are enqueued after the queue has
+	// been shut down.
+	ErrQueueClosed = errors.New("delivery queue is closed")
+)
+
+// replayInterval is how long the replay of the outbox waits for room in the
+// queue before trying again.
+const replayInterval = 100 * time.Millisecond
+
+var tracer = tracing.Tracer("delivery")
+
+// Queue is a bounded, in-memory queue of notifications drained by a pool of
+// workers that deliver them to the notification services. If an outbox is
+// configured, jobs are persisted before they are enqueued and removed once
+// they are either delivered or moved to the dead-letter store, so that
+// pending notifications survive restarts.
+type Queue struct {
+	// settingsMu guards retry, endpoints, notifiers and fallbacks, which are
+	// replaced when the configuration is reloaded. endpoints contains the
+	// settings the notifiers and fallbacks were created from, and fallbacks
+	// the email fallbacks of the endpoints enabling one.
+	settingsMu sync.RWMutex
+	retry      conf.Retry
+	endpoints  map[string]conf.Ntfy
+	notifiers  map[string]notify.Notifier
+	fallbacks  map[string]*fallback
+
+	outbox      *outbox.Outbox
+	deadLetters *deadletter.Store
+	dedup       *dedup.Cache
+	jobs        chan Job
+
+	// limiter is nil if rate limiting is disabled. overflowMu guards
+	// overflows, which tracks the notifications held back per topic.
+	// stopSummaries ensures the summaries are stopped only once, as Shutdown
+	// may be called again.
+	limiter       *ratelimit.Limiter
+	overflowMu    sync.Mutex
+	overflows     map[string]*overflow
+	summaryStop   chan struct{}
+	summaryDone   chan struct{}
+	stopSummaries sync.Once
+
+	// mu guards closed and serializes the sends of producers, so that a batch
+	// of jobs is either enqueued as a whole or not at all.
+	mu     sync.Mutex
+	closed bool
+
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewQueue creates a delivery queue and starts its workers. The queue delivers
+// notifications using the notifier of the endpoint they were received on, and
+// records the ones that fail permanently in the dead-letter store. The
+// notifications that are not delivered are forgotten by the dedup cache dd,
+// which may be nil. If an outbox directory is configured, the notifications
+// pending in it are replayed in the background.
+func NewQueue(c conf.C, dl *deadletter.Store, dd *dedup.Cache) (*Queue, error) {
+	d := c.Delivery
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue{
+		retry:       d.Retry,
+		deadLetters: dl,
+		dedup:       dd,
+		jobs:        make(chan Job, d.Queue.Size),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+
+	if err := q.setNotifiers(c); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	var pending []outbox.Entry
+	if d.Outbox.Dir != "" {
+		ob, err := outbox.Open(d.Outbox.Dir)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("opening outbox: %w", err)
+		}
+		pending, err = ob.List()
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("reading outbox: %w", err)
+		}
+		q.outbox = ob
+	}
+
+	if rl := d.RateLimit; rl.MessagesPerMinute > 0 {
+		q.limiter = ratelimit.New(rl.MessagesPerMinute, time.Minute, rl.Burst)
+		q.overflows = make(map[string]*overflow)
+		q.summaryStop = make(chan struct{})
+		q.summaryDone = make(chan struct{})
+		go q.summarize(rl.SummaryInterval)
+	}
+
+	for range d.Queue.Workers {
+		q.wg.Add(1)
+		go q.work()
+	}
+	if len(pending) != 0 {
+		go q.replay(pending)
+	}
+	return q, nil
+}
+
+// Reload replaces the retry configuration and the notifiers of the endpoints
+// with those of the provided configuration. The notifiers and fallbacks of
+// the endpoints whose delivery settings are unchanged are kept, along with
+// the state of their circuit breakers and fallback timers. Deliveries in
+// progress use the new configuration from their next attempt on. The other
+// settings of the queue only take effect on restart. If creating the
+// notifiers fails, the queue is left unchanged.
+func (q *Queue) Reload(c conf.C) error {
+	q.settingsMu.Lock()
+	defer q.settingsMu.Unlock()
+	if err := q.setNotifiers(c); err != nil {
+		return err
+	}
+	q.retry = c.Delivery.Retry
+	return nil
+}
+
+// settings returns the retry configuration, and the notifier and fallback of
+// the endpoint. The notifier is nil if the endpoint is not configured, and
+// the fallback is nil unless the endpoint enables one.
+func (q *Queue) settings(endpoint string) (conf.Retry, notify.Notifier, *fallback) {
+	q.settingsMu.RLock()
+	defer q.settingsMu.RUnlock()
+	return q.retry, q.notifiers[endpoint], q.fallbacks[endpoint]
+}
+
+// setNotifiers sets the notifiers and email fallbacks of the default
+// endpoint, keyed by the empty name, and of the additional endpoints. Those of
+// the endpoints whose delivery settings are the same as when they were created
+// are kept. If creating a notifier fails, nothing is changed. q.settingsMu
+// must be held, unless the queue is being created.
+func (q *Queue) setNotifiers(c conf.C) error {
+	endpoints := make(map[string]conf.Ntfy, len(c.Endpoints)+1)
+	endpoints[""] = transport(c.Ntfy)
+	for name, e := range c.Endpoints {
+		endpoints[name] = transport(e)
+	}
+
+	notifiers := make(map[string]notify.Notifier, len(endpoints))
+	fallbacks := make(map[string]*fallback)
+	for name, e := range endpoints {
+		if prev, ok := q.endpoints[name]; ok && reflect.DeepEqual(prev, e) {
+			notifiers[name] = q.notifiers[name]
+			if f, ok := q.fallbacks[name]; ok {
+				fallbacks[name] = f
+			}
+			continue
+		}
+		label := name
+		if name == "" {
+			label = conf.DefaultEndpoint
+		}
+		n, err := notify.New(label, e, http.DefaultClient)
+		if err != nil {
+			if name == "" {
+				return err
+			}
+			return fmt.Errorf("endpoint %q: %w", name, err)
+		}
+		notifiers[name] = n
+		if e.Fallback.Enable {
+			fallbacks[name] = newFallback(e)
+		}
+	}
+	q.endpoints = endpoints
+	q.notifiers = notifiers
+	q.fallbacks = fallbacks
+	return nil
+}
+
+// transport returns the settings of the endpoint the notifiers and fallbacks
+// are created from, leaving out the templates and expressions, which are only
+// used for rendering and never compare equal once parsed again.
+func transport(n conf.Ntfy) conf.Ntfy {
+	n.Notification = conf.Notification{}
+	n.Routes = nil
+	n.Webhook = conf.Webhook{}
+	n.Email.HTML = nil
+	return n
+}
+
+// Enqueue adds the provided jobs to the queue, persisting them to the outbox
+// first if one is configured. Either all jobs are enqueued or none of them
+// are.
+func (q *Queue) Enqueue(jobs ...Job) error {
+	if len(jobs) > cap(q.jobs) {
+		return ErrBatchTooLarge
+	}
+	// the outbox is written without holding the lock, so that producers are
+	// not serialized behind disk syncs. The room is checked beforehand to
+	// not persist jobs that cannot be enqueued, and again once persisted.
+	q.mu.Lock()
+	err := q.hasRoom(len(jobs))
+	q.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := q.persist(jobs); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.hasRoom(len(jobs)); err != nil {
+		q.unpersist(jobs)
+		return err
+	}
+	for _, j := range jobs {
+		q.jobs <- j
+	}
+	metrics.QueueDepth.Set(float64(len(q.jobs)))
+	return nil
+}
+
+// hasRoom returns an error if n jobs cannot be enqueued, either because the
+// queue is closed or because it is full. q.mu must be held.
+func (q *Queue) hasRoom(n int) error {
+	if q.closed {
+		return ErrQueueClosed
+	}
+	if cap(q.jobs)-len(q.jobs) < n {
+		return ErrQueueFull
+	}
+	return nil
+}
+
+// Len returns the number of jobs waiting in the queue.
+func (q *Queue) Len() int {
+	return len(q.jobs)
+}
+
+// Shutdown enqueues the pending rate limit summaries, stops accepting new jobs
+// and waits for the workers to drain the queue. If the context expires first,
+// in-flight deliveries are cancelled and the context's error is returned. It is
+// safe to call Shutdown more than once.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	if q.limiter != nil {
+		q.stopSummaries.Do(func() {
+			close(q.summaryStop)
+			<-q.summaryDone
+			q.flushOverflows()
+		})
+	}
+
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// persist assigns IDs to the jobs and writes them to the outbox. If any write
+// fails, the jobs written so far are removed again.
+func (q *Queue) persist(jobs []Job) error {
+	if q.outbox == nil {
+		return nil
+	}
+	for i := range jobs {
+		jobs[i].ID = outbox.NewID()
+		data, err := json.Marshal(jobs[i])
+		if err == nil {
+			err = q.outbox.Put(jobs[i].ID, data)
+		}
+		if err != nil {
+			q.unpersist(jobs[:i])
+			return fmt.Errorf("persisting job to outbox: %w", err)
+		}
+	}
+	return nil
+}
+
+// unpersist removes jobs that could not be enqueued from the outbox.
+func (q *Queue) unpersist(jobs []Job) {
+	if q.outbox == nil {
+		return
+	}
+	for _, j := range jobs {
+		q.outbox.Delete(j.ID)
+	}
+}
+
+// replay enqueues the jobs left pending in the outbox by a previous run,
+// waiting for room in the queue as needed.
+func (q *Queue) replay(entries []outbox.Entry) {
+	slog.LogAttrs(
+		q.ctx,
+		slog.LevelInfo,
+		"replaying pending notifications from outbox",
+		slog.Int("pending", len(entries)),
+	)
+	for _, e := range entries {
+		var j Job
+		if err := json.Unmarshal(e.Data, &j); err != nil {
+			slog.LogAttrs(
+				q.ctx,
+				slog.LevelError,
+				"failed to decode outbox entry. Skipping",
+				slog.String("id", e.ID),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		j.ID = e.ID
+
+		for {
+			q.mu.Lock()
+			if q.closed {
+				q.mu.Unlock()
+				return
+			}
+			enqueued := len(q.jobs) < cap(q.jobs)
+			if enqueued {
+				q.jobs <- j
+				metrics.QueueDepth.Set(float64(len(q.jobs)))
+			}
+			q.mu.Unlock()
+			if enqueued {
+				break
+			}
+			time.Sleep(replayInterval)
+		}
+	}
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for j := range q.jobs {
+		metrics.QueueDepth.Set(float64(len(q.jobs)))
+		if q.ctx.Err() != nil {
+			msg := "queue shut down before notification was delivered. Dropping"
+			if q.outbox != nil {
+				msg = "queue shut down before notification was delivered. " +
+					"Keeping it in the outbox"
+			} else {
+				q.dedup.Forget(j.Dedup)
+			}
+			slog.LogAttrs(q.ctx, slog.LevelError, msg, j.LogAttr())
+			continue
+		}
+
+		if !q.allow(j) {
+			q.dedup.Forget(j.Dedup)
+			q.remove(j)
+			continue
+		}
+
+		ctx, span := tracer.Start(
+			tracing.Extract(q.ctx, j.TraceContext),
+			"Queue.deliver",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				attribute.String("alertfy.fingerprint", j.Fingerprint),
+				attribute.String("alertfy.group_key", j.GroupKey),
+				attribute.String("ntfy.topic", j.Notification.Topic),
+				attribute.Bool("alertfy.summary", j.Summary),
+			),
+		)
+		history, err := q.deliver(ctx, j)
+		if err != nil {
+			tracing.Fail(span, err)
+		}
+		span.End()
+		if err != nil && q.ctx.Err() != nil {
+			// interrupted by shutdown; keep the job in the outbox, if any,
+			// so that it is delivered again on startup
+			if q.outbox == nil {
+				q.dedup.Forget(j.Dedup)
+			}
+			continue
+		}
+		n := j.Notification
+		if err != nil {
+			metrics.NotificationsFailed.WithLabelValues(n.Topic, n.Priority).Inc()
+			q.dedup.Forget(j.Dedup)
+			q.deadLetter(j, err, history)
+		} else {
+			metrics.NotificationsSent.WithLabelValues(n.Topic, n.Priority).Inc()
+		}
+		q.remove(j)
+	}
+}
+
+// deadLetter records a job that failed permanently in the dead-letter store.
+func (q *Queue) deadLetter(j Job, cause error, history []deadletter.Attempt) {
+	e, err := q.deadLetters.Add(j.DeadLetter(cause, history))
+	if err != nil {
+		slog.LogAttrs(
+			q.ctx,
+			slog.LevelError,
+			"failed to add notification to dead-letter store",
+			j.LogAttr(),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+	slog.LogAttrs(
+		q.ctx,
+		slog.LevelWarn,
+		"notification moved to dead-letter store",
+		j.LogAttr(),
+		slog.String("id", e.ID),
+	)
+}
+
+// remove deletes a job from the outbox, if one is configured.
+func (q *Queue) remove(j Job) {
+	if q.outbox == nil {
+		return
+	}
+	if err := q.outbox.Delete(j.ID); err != nil {
+		slog.LogAttrs(
+			q.ctx,
+			slog.LevelError,
+			"failed to remove notification from outbox",
+			j.LogAttr(),
+			slog.String("id", j.ID),
+			slog.String("error", err.Error()),
+		)
+	}
+}
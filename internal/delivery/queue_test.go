@@ -0,0 +1,251 @@
+package delivery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/deadletter"
+	"github.com/murtaza-u/alertfy/internal/ntfy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReload(t *testing.T) {
+	a := assert.New(t)
+	var hits []string
+	newServer := func(name string) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				hits = append(hits, name+r.URL.Path)
+			},
+		))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	before, after := newServer("before"), newServer("after")
+
+	c := conf.C{
+		Ntfy: conf.Ntfy{Notifier: conf.NotifierNtfy, BaseURL: before.URL},
+		Delivery: conf.Delivery{
+			Queue: conf.Queue{Size: 1, Workers: 1},
+			Retry: conf.Retry{MaxAttempts: 1},
+		},
+	}
+	dl, err := deadletter.New(10, "")
+	require.NoError(t, err)
+	q, err := NewQueue(c, dl, nil)
+	require.NoError(t, err)
+	defer q.Shutdown(context.Background())
+
+	deliver := func(endpoint string) error {
+		_, err := q.deliver(context.Background(), Job{
+			Endpoint:     endpoint,
+			Notification: ntfy.Data{Topic: "alerts"},
+		})
+		return err
+	}
+	a.NoError(deliver(""))
+	a.Error(deliver("team"), "endpoint is not configured yet")
+
+	c.Ntfy.BaseURL = after.URL
+	c.Endpoints = map[string]conf.Ntfy{
+		"team": {Notifier: conf.NotifierNtfy, BaseURL: after.URL},
+	}
+	c.Delivery.Retry.MaxAttempts = 3
+	require.NoError(t, q.Reload(c))
+	a.NoError(deliver(""))
+	a.NoError(deliver("team"))
+	retry, _, _ := q.settings("")
+	a.Equal(3, retry.MaxAttempts)
+
+	c.Ntfy.Notifier = "carrier-pigeon"
+	a.Error(q.Reload(c))
+	a.NoError(deliver(""), "the previous notifiers are kept")
+	a.Equal([]string{"before/alerts", "after/alerts", "after/alerts", "after/alerts"}, hits)
+}
+
+// template parses the template as it is when loaded from the config file.
+func template(t *testing.T, text string) *conf.Template {
+	tmpl := new(conf.Template)
+	require.NoError(t, tmpl.UnmarshalText([]byte(text)))
+	return tmpl
+}
+
+func TestReloadKeepsBreakers(t *testing.T) {
+	a := assert.New(t)
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			hits++
+			w.WriteHeader(http.StatusServiceUnavailable)
+		},
+	))
+	defer srv.Close()
+
+	c := conf.C{
+		Ntfy: conf.Ntfy{
+			Notifier:       conf.NotifierNtfy,
+			BaseURL:        srv.URL,
+			CircuitBreaker: conf.CircuitBreaker{Failures: 1, OpenFor: time.Hour},
+			Fallback:       conf.Fallback{Enable: true, After: time.Hour},
+			Notification:   conf.Notification{Title: template(t, "before")},
+		},
+		Delivery: conf.Delivery{
+			Queue: conf.Queue{Size: 1},
+			Retry: conf.Retry{MaxAttempts: 1},
+		},
+	}
+	dl, err := deadletter.New(10, "")
+	require.NoError(t, err)
+	q, err := NewQueue(c, dl, nil)
+	require.NoError(t, err)
+	defer q.Shutdown(context.Background())
+
+	notify := func() {
+		_, n, _ := q.settings("")
+		_, err := n.Notify(context.Background(), ntfy.Data{Topic: "alerts"})
+		a.NotNil(err)
+	}
+	notify()
+	a.Equal(1, hits)
+	_, _, fb := q.settings("")
+
+	c.Ntfy.Notification.Title = template(t, "after")
+	c.Delivery.Retry.MaxAttempts = 3
+	require.NoError(t, q.Reload(c))
+	notify()
+	a.Equal(1, hits, "the breaker stays open across reloads")
+	_, _, reloaded := q.settings("")
+	a.Same(fb, reloaded, "the fallback is kept across reloads")
+
+	c.Ntfy.CircuitBreaker.OpenFor = time.Minute
+	require.NoError(t, q.Reload(c))
+	notify()
+	a.Equal(2, hits, "the notifier is created again if its settings changed")
+}
+
+func TestEnqueue(t *testing.T) {
+	a := assert.New(t)
+	c := conf.C{
+		Ntfy: conf.Ntfy{Notifier: conf.NotifierNtfy, BaseURL: "http://127.0.0.1:0"},
+		// without workers, nothing is drained from the queue
+		Delivery: conf.Delivery{Queue: conf.Queue{Size: 2}},
+	}
+	dl, err := deadletter.New(10, "")
+	require.NoError(t, err)
+	q, err := NewQueue(c, dl, nil)
+	require.NoError(t, err)
+
+	job := Job{Notification: ntfy.Data{Topic: "alerts"}}
+	a.ErrorIs(q.Enqueue(job, job, job), ErrBatchTooLarge)
+	a.Zero(q.Len())
+	a.NoError(q.Enqueue(job))
+	a.ErrorIs(q.Enqueue(job, job), ErrQueueFull, "batches are enqueued as a whole")
+	a.Equal(1, q.Len())
+	a.NoError(q.Enqueue(job))
+	a.ErrorIs(q.Enqueue(job), ErrQueueFull)
+
+	a.NoError(q.Shutdown(context.Background()))
+	a.ErrorIs(q.Enqueue(job), ErrQueueClosed)
+}
+
+func TestEnqueueOutbox(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	c := conf.C{
+		Ntfy: conf.Ntfy{Notifier: conf.NotifierNtfy, BaseURL: "http://127.0.0.1:0"},
+		// without workers, nothing is drained from the queue
+		Delivery: conf.Delivery{
+			Queue:  conf.Queue{Size: 2},
+			Outbox: conf.Outbox{Dir: dir},
+		},
+	}
+	dl, err := deadletter.New(10, "")
+	require.NoError(t, err)
+	q, err := NewQueue(c, dl, nil)
+	require.NoError(t, err)
+	defer q.Shutdown(context.Background())
+
+	pending := func() int {
+		entries, err := q.outbox.List()
+		require.NoError(t, err)
+		return len(entries)
+	}
+	job := Job{Notification: ntfy.Data{Topic: "alerts"}}
+	a.NoError(q.Enqueue(job))
+	a.Equal(1, pending())
+	a.ErrorIs(q.Enqueue(job, job), ErrQueueFull)
+	a.Equal(1, pending(), "jobs that are not enqueued are not persisted")
+	a.NoError(q.Enqueue(job))
+	a.Equal(2, pending())
+}
+
+func TestShutdownDrainsQueue(t *testing.T) {
+	a := assert.New(t)
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var delivered int
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			mu.Lock()
+			delivered++
+			mu.Unlock()
+		},
+	))
+	defer srv.Close()
+
+	c := conf.C{
+		Ntfy: conf.Ntfy{Notifier: conf.NotifierNtfy, BaseURL: srv.URL},
+		Delivery: conf.Delivery{
+			Queue: conf.Queue{Size: 10, Workers: 1},
+			Retry: conf.Retry{MaxAttempts: 1},
+		},
+	}
+	dl, err := deadletter.New(10, "")
+	require.NoError(t, err)
+	q, err := NewQueue(c, dl, nil)
+	require.NoError(t, err)
+
+	job := Job{Notification: ntfy.Data{Topic: "alerts"}}
+	require.NoError(t, q.Enqueue(job, job, job))
+	time.AfterFunc(50*time.Millisecond, func() { close(release) })
+	a.NoError(q.Shutdown(context.Background()))
+	a.Equal(3, delivered, "pending jobs are delivered before shutting down")
+	a.Empty(dl.List())
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	a := assert.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		},
+	))
+	defer srv.Close()
+
+	c := conf.C{
+		Ntfy: conf.Ntfy{Notifier: conf.NotifierNtfy, BaseURL: srv.URL},
+		Delivery: conf.Delivery{
+			Queue: conf.Queue{Size: 10, Workers: 1},
+			Retry: conf.Retry{MaxAttempts: 1},
+		},
+	}
+	dl, err := deadletter.New(10, "")
+	require.NoError(t, err)
+	q, err := NewQueue(c, dl, nil)
+	require.NoError(t, err)
+
+	job := Job{Notification: ntfy.Data{Topic: "alerts"}}
+	require.NoError(t, q.Enqueue(job, job))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	a.ErrorIs(q.Shutdown(ctx), context.DeadlineExceeded)
+	a.Empty(dl.List(), "interrupted deliveries are not dead-lettered")
+}
@@ -0,0 +1,48 @@
+package delivery
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// backoff returns how long to wait before the attempt following the provided
+// one. The delay grows exponentially with every attempt and is randomized by
+// the configured jitter. A delay requested by the notification service takes
+// precedence.
+// The result never exceeds the configured maximum backoff.
+func (q *Queue) backoff(attempt int, requested time.Duration) time.Duration {
+	q.settingsMu.RLock()
+	retry := q.retry
+	q.settingsMu.RUnlock()
+
+	limit := retry.MaxBackoff
+	if requested > 0 {
+		return min(requested, limit)
+	}
+
+	d := float64(retry.InitialBackoff) *
+		math.Pow(retry.Multiplier, float64(attempt-1))
+	if retry.Jitter > 0 {
+		// scale by a random factor in [1-jitter, 1+jitter)
+		d *= 1 + retry.Jitter*(2*rand.Float64()-1)
+	}
+	if d >= float64(limit) {
+		return limit
+	}
+	return time.Duration(d)
+}
+
+// sleep waits for the provided duration. It returns false if the context is
+// done before the duration elapses.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,93 @@
+package delivery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/notify"
+	"github.com/murtaza-u/alertfy/internal/ntfy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	a := assert.New(t)
+	q := &Queue{retry: conf.Retry{
+		InitialBackoff: time.Second,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+	}}
+	a.Equal(time.Second, q.backoff(1, 0))
+	a.Equal(2*time.Second, q.backoff(2, 0))
+	a.Equal(8*time.Second, q.backoff(4, 0))
+	a.Equal(10*time.Second, q.backoff(5, 0))
+	a.Equal(3*time.Second, q.backoff(1, 3*time.Second))
+	a.Equal(10*time.Second, q.backoff(1, time.Hour))
+
+	q.retry.Jitter = 0.5
+	for range 100 {
+		d := q.backoff(2, 0)
+		a.GreaterOrEqual(d, time.Second)
+		a.Less(d, 3*time.Second)
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	type input struct {
+		statuses  []int
+		attempts  int
+		delivered bool
+	}
+	inputs := []input{
+		{statuses: []int{200}, attempts: 1, delivered: true},
+		{statuses: []int{503, 429, 200}, attempts: 3, delivered: true},
+		{statuses: []int{500, 500, 500}, attempts: 3, delivered: false},
+		{statuses: []int{400}, attempts: 1, delivered: false},
+	}
+
+	for _, i := range inputs {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				n := int(calls.Add(1))
+				if n > len(i.statuses) {
+					n = len(i.statuses)
+				}
+				status := i.statuses[n-1]
+				if status == http.StatusTooManyRequests {
+					w.Header().Set("Retry-After", "0")
+				}
+				w.WriteHeader(status)
+			},
+		))
+
+		n, err := notify.New(conf.DefaultEndpoint, conf.Ntfy{
+			Notifier: conf.NotifierNtfy,
+			BaseURL:  srv.URL,
+		}, srv.Client())
+		assert.NoError(t, err)
+		q := &Queue{
+			notifiers: map[string]notify.Notifier{"": n},
+			retry: conf.Retry{
+				MaxAttempts:    3,
+				InitialBackoff: time.Millisecond,
+				MaxBackoff:     time.Millisecond,
+				Multiplier:     2,
+			},
+		}
+		history, err := q.deliver(context.Background(), Job{
+			Notification: ntfy.Data{Topic: "topic"},
+		})
+		assert.Equalf(t, i.delivered, err == nil, "statuses=%v", i.statuses)
+		if !i.delivered {
+			assert.Lenf(t, history, i.attempts, "statuses=%v", i.statuses)
+		}
+		assert.Equalf(t, i.attempts, int(calls.Load()), "statuses=%v", i.statuses)
+		srv.Close()
+	}
+}
@@ -0,0 +1,119 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/murtaza-u/alertfy/internal/deadletter"
+	"github.com/murtaza-u/alertfy/internal/notify"
+)
+
+// deliver publishes the job's notification, retrying retryable failures
+// according to the retry configuration. If the endpoint has an email fallback
+// that applies to the notification, it is sent by email after each failed
+// attempt until that succeeds. It returns nil if the notification service or
+// the fallback accepted the notification. Otherwise, it returns the last
+// error along with the history of failed attempts. Failures are logged.
+func (q *Queue) deliver(ctx context.Context, j Job) ([]deadletter.Attempt, error) {
+	var history []deadletter.Attempt
+	for attempt := 1; ; attempt++ {
+		// the configuration may be reloaded between attempts
+		retry, n, fb := q.settings(j.Endpoint)
+		server, err := send(ctx, n, j)
+		if fb != nil {
+			fb.record(err == nil)
+		}
+		if err == nil {
+			slog.LogAttrs(
+				ctx,
+				slog.LevelInfo,
+				"notification delivered",
+				j.LogAttr(),
+				slog.String("topic", j.Notification.Topic),
+				slog.String("server", server),
+				slog.Int("attempt", attempt),
+			)
+			return nil, nil
+		}
+		history = append(history, deadletter.Attempt{
+			At:    time.Now(),
+			Error: err.Error(),
+		})
+
+		if fb != nil && fb.applies(j.Notification) {
+			server, ferr := fb.notify(ctx, j.Notification)
+			if ferr == nil {
+				slog.LogAttrs(
+					ctx,
+					slog.LevelWarn,
+					"notification delivered by email fallback",
+					j.LogAttr(),
+					slog.String("server", server),
+					slog.Int("attempt", attempt),
+					slog.String("error", err.Error()),
+				)
+				return nil, nil
+			}
+			history = append(history, deadletter.Attempt{
+				At:    time.Now(),
+				Error: ferr.Error(),
+			})
+			slog.LogAttrs(
+				ctx,
+				slog.LevelError,
+				"failed to deliver notification by email fallback",
+				j.LogAttr(),
+				slog.Int("attempt", attempt),
+				slog.String("error", ferr.Error()),
+			)
+		}
+
+		if !err.Retryable || attempt >= retry.MaxAttempts {
+			slog.LogAttrs(
+				ctx,
+				slog.LevelError,
+				"failed to deliver notification. Aborting",
+				j.LogAttr(),
+				slog.String("topic", j.Notification.Topic),
+				slog.Int("attempt", attempt),
+				slog.Bool("retryable", err.Retryable),
+				slog.String("error", err.Error()),
+			)
+			return history, err
+		}
+
+		wait := q.backoff(attempt, err.RetryAfter)
+		slog.LogAttrs(
+			ctx,
+			slog.LevelWarn,
+			"failed to deliver notification. Retrying",
+			j.LogAttr(),
+			slog.String("topic", j.Notification.Topic),
+			slog.Int("attempt", attempt),
+			slog.Duration("backoff", wait),
+			slog.String("error", err.Error()),
+		)
+		if !sleep(ctx, wait) {
+			slog.LogAttrs(
+				ctx,
+				slog.LevelError,
+				"delivery cancelled while waiting to retry",
+				j.LogAttr(),
+				slog.Int("attempt", attempt),
+			)
+			return history, ctx.Err()
+		}
+	}
+}
+
+// send makes a single attempt at publishing the job's notification using the
+// notifier of the endpoint it was received on, which is nil if the endpoint is
+// no longer configured. It returns the server that accepted the notification.
+func send(ctx context.Context, n notify.Notifier, j Job) (string, *notify.Error) {
+	if n == nil {
+		return "", &notify.Error{Err: fmt.Errorf("endpoint %q is not configured", j.Endpoint)}
+	}
+	return n.Notify(ctx, j.Notification)
+}
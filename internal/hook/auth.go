@@ -0,0 +1,218 @@
+package hook
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// credential is a credential accepted by the webhook.
+type credential struct {
+	name     string
+	username []byte
+	password []byte
+	// hashed reports whether password is a bcrypt hash.
+	hashed bool
+	token  []byte
+}
+
+// authenticator authenticates requests against the configured credentials.
+type authenticator struct {
+	credentials []credential
+	// htpasswd is nil if no htpasswd file is configured.
+	htpasswd *reloader[[]credential]
+}
+
+func newAuthenticator(c conf.HookAuth) (*authenticator, error) {
+	a := new(authenticator)
+	if c.Username != "" {
+		a.credentials = append(a.credentials,
+			newCredential(c.Username, c.Username, c.Password))
+	}
+	for _, cred := range c.Credentials {
+		if cred.Token != "" {
+			a.credentials = append(a.credentials, credential{
+				name:  cred.Name,
+				token: []byte(cred.Token),
+			})
+			continue
+		}
+		a.credentials = append(a.credentials,
+			newCredential(cred.Name, cred.Username, cred.Password))
+	}
+
+	if c.HtpasswdFile != "" {
+		htpasswd, err := newReloader("htpasswd file", func() ([]credential, error) {
+			return loadHtpasswd(c.HtpasswdFile)
+		}, c.HtpasswdFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading htpasswd file: %w", err)
+		}
+		a.htpasswd = htpasswd
+	}
+	return a, nil
+}
+
+func newCredential(name, username, password string) credential {
+	return credential{
+		name:     name,
+		username: []byte(username),
+		password: []byte(password),
+		hashed:   isBcrypt(password),
+	}
+}
+
+// isBcrypt reports whether the password is a bcrypt hash.
+func isBcrypt(password string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(password, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadHtpasswd reads the credentials from an htpasswd file. Only bcrypt
+// hashes are supported.
+func loadHtpasswd(path string) ([]credential, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var creds []credential
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		username, hash, ok := strings.Cut(line, ":")
+		if !ok || username == "" {
+			return nil, fmt.Errorf("line %d: expected `username:hash`", n)
+		}
+		if !isBcrypt(hash) {
+			return nil, fmt.Errorf("line %d: unsupported hash for user %q; only bcrypt is supported",
+				n, username)
+		}
+		creds = append(creds, newCredential(username, username, hash))
+	}
+	return creds, scanner.Err()
+}
+
+// authenticate returns the name of the credential presented by the request,
+// if any of the configured credentials matches.
+func (a *authenticator) authenticate(r *http.Request) (string, bool) {
+	creds := a.credentials
+	if a.htpasswd != nil {
+		creds = append(creds[:len(creds):len(creds)], a.htpasswd.get()...)
+	}
+
+	if username, password, ok := r.BasicAuth(); ok {
+		return matchBasic(creds, []byte(username), []byte(password))
+	}
+	scheme, token, ok := strings.Cut(r.Header.Get(echo.HeaderAuthorization), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return matchToken(creds, []byte(strings.TrimSpace(token)))
+	}
+	return "", false
+}
+
+// dummyHash is the bcrypt hash compared against when no hashed credential
+// has the username, so that the time taken does not reveal which usernames
+// exist.
+var dummyHash = []byte("$2a$10$G6WhNl/sMz44WDUMNBk6qevvQCYQEyQFbTViyF5IjwR17sgdqht8i")
+
+// matchBasic returns the name of the credential matching the username and
+// password. Usernames and plain text passwords are compared in constant time,
+// and a bcrypt comparison is made whether the username exists or not.
+func matchBasic(creds []credential, username, password []byte) (string, bool) {
+	var name string
+	var ok, compared bool
+	for _, cred := range creds {
+		if cred.username == nil ||
+			subtle.ConstantTimeCompare(username, cred.username) != 1 {
+			continue
+		}
+		var match bool
+		if cred.hashed {
+			match = bcrypt.CompareHashAndPassword(cred.password, password) == nil
+			compared = true
+		} else {
+			match = subtle.ConstantTimeCompare(password, cred.password) == 1
+		}
+		if match && !ok {
+			name, ok = cred.name, true
+		}
+	}
+	if !compared {
+		bcrypt.CompareHashAndPassword(dummyHash, password)
+	}
+	return name, ok
+}
+
+// matchToken returns the name of the credential matching the bearer token.
+// Every token is compared, so that the time taken does not reveal which
+// token matched.
+func matchToken(creds []credential, token []byte) (string, bool) {
+	var name string
+	var ok bool
+	for _, cred := range creds {
+		if cred.token == nil {
+			continue
+		}
+		if subtle.ConstantTimeCompare(token, cred.token) == 1 && !ok {
+			name, ok = cred.name, true
+		}
+	}
+	return name, ok
+}
+
+// authenticate rejects requests that do not present any of the configured
+// credentials, if authentication is enabled. The name of the credential is
+// added to every log record of the request. Requests with a valid signature
+// are accepted without credentials.
+func (h Hook) authenticate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		auth := h.current().auth
+		if auth == nil || c.Get(signedKey) == true {
+			return next(c)
+		}
+		req := c.Request()
+		name, ok := auth.authenticate(req)
+		if !ok {
+			slog.LogAttrs(
+				req.Context(),
+				slog.LevelWarn,
+				"rejected request with missing or invalid credentials",
+				slog.String("remoteAddr", req.RemoteAddr),
+				slog.String("path", req.URL.Path),
+				slog.Int("status", http.StatusUnauthorized),
+			)
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="alertfy"`)
+			return c.NoContent(http.StatusUnauthorized)
+		}
+
+		ctx := withLogAttrs(req.Context(), slog.String("credential", name))
+		c.SetRequest(req.WithContext(ctx))
+		err := next(c)
+		slog.LogAttrs(
+			ctx,
+			slog.LevelInfo,
+			"handled authenticated request",
+			slog.String("method", req.Method),
+			slog.String("path", req.URL.Path),
+			slog.Int("status", c.Response().Status),
+		)
+		return err
+	}
+}
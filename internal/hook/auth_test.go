@@ -0,0 +1,151 @@
+package hook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthenticate(t *testing.T) {
+	a := assert.New(t)
+	hash, err := bcrypt.GenerateFromPassword([]byte("hashed"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	htpasswd := filepath.Join(t.TempDir(), ".htpasswd")
+	require.NoError(t, os.WriteFile(htpasswd,
+		[]byte("# comment\nfile:"+string(hash)+"\n"), 0o600))
+
+	auth, err := newAuthenticator(conf.HookAuth{
+		Enable:   true,
+		Username: "legacy",
+		Password: "plain",
+		Credentials: []conf.Credential{
+			{Name: "eu", Username: "eu", Password: string(hash)},
+			{Name: "us", Token: "secret"},
+		},
+		HtpasswdFile: htpasswd,
+	})
+	require.NoError(t, err)
+
+	basic := func(username, password string) (string, bool) {
+		req := httptest.NewRequest("POST", "/hook", nil)
+		req.SetBasicAuth(username, password)
+		return auth.authenticate(req)
+	}
+	bearer := func(token string) (string, bool) {
+		req := httptest.NewRequest("POST", "/hook", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return auth.authenticate(req)
+	}
+
+	name, ok := basic("legacy", "plain")
+	a.True(ok)
+	a.Equal("legacy", name)
+	name, ok = basic("eu", "hashed")
+	a.True(ok)
+	a.Equal("eu", name)
+	name, ok = basic("file", "hashed")
+	a.True(ok)
+	a.Equal("file", name)
+	name, ok = bearer("secret")
+	a.True(ok)
+	a.Equal("us", name)
+
+	_, ok = basic("legacy", "wrong")
+	a.False(ok)
+	_, ok = basic("eu", string(hash))
+	a.False(ok, "hash is not accepted as password")
+	_, ok = bearer("wrong")
+	a.False(ok)
+	_, ok = auth.authenticate(httptest.NewRequest("POST", "/hook", nil))
+	a.False(ok)
+
+	// rotate the htpasswd file
+	require.NoError(t, os.WriteFile(htpasswd,
+		[]byte("rotated:"+string(hash)+"\n"), 0o600))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(htpasswd, later, later))
+	name, ok = basic("rotated", "hashed")
+	a.True(ok)
+	a.Equal("rotated", name)
+	_, ok = basic("file", "hashed")
+	a.False(ok)
+}
+
+func TestDummyHash(t *testing.T) {
+	// unknown usernames take as long as hashes generated by `htpasswd -B`
+	cost, err := bcrypt.Cost(dummyHash)
+	require.NoError(t, err)
+	assert.Equal(t, bcrypt.DefaultCost, cost)
+
+	name, ok := matchBasic([]credential{newCredential("bob", "bob", "plain")},
+		[]byte("alice"), []byte("plain"))
+	assert.False(t, ok)
+	assert.Empty(t, name)
+}
+
+func TestLoadHtpasswd(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "htpasswd")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	_, err := loadHtpasswd(write("bob:{SHA}fCIvspJ9goryL1khNOiTJIBjfA0=\n"))
+	assert.Error(t, err, "only bcrypt is supported")
+	_, err = loadHtpasswd(write("bob\n"))
+	assert.Error(t, err)
+}
+
+func TestAdminAPIAuth(t *testing.T) {
+	a := assert.New(t)
+	const ntfy = `
+ntfy:
+  baseUrl: http://127.0.0.1:0
+  notification:
+    topic: alerts
+    title: title
+    description: description
+`
+	get := func(e http.Handler, token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/deadletters", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	_, e := newTestHook(t, ntfy).servers()
+	a.Equal(http.StatusForbidden, get(e, ""),
+		"the API is not served on the webhook listener without auth")
+
+	servers, e := newTestHook(t, ntfy+`
+hook:
+  adminListen: 127.0.0.1:0
+`).servers()
+	a.Equal(http.StatusNotFound, get(e, ""))
+	a.Equal(http.StatusOK, get(servers["127.0.0.1:0"], ""))
+
+	_, e = newTestHook(t, ntfy+`
+hook:
+  auth:
+    enable: true
+    credentials:
+      - name: admin
+        token: secret
+`).servers()
+	a.Equal(http.StatusUnauthorized, get(e, ""))
+	a.Equal(http.StatusOK, get(e, "secret"))
+}
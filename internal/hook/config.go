@@ -0,0 +1,95 @@
+package hook
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+)
+
+// restartKeys are the configuration keys, or prefixes of keys, whose changes
+// only take effect after a restart.
+var restartKeys = []string{
+	"hook.listen",
+	"hook.adminListen",
+	"hook.tls",
+	"delivery.queue",
+	"delivery.outbox",
+	"delivery.deadLetter",
+	"delivery.dedup",
+	"delivery.rateLimit",
+	"tracing",
+}
+
+// Reload replaces the configuration of the webhook with the provided one,
+// which must be valid. Requests being served and deliveries in progress
+// finish with the configuration they started with. Changes to the listeners,
+// TLS, the delivery queue, outbox, dead-letter store, dedup cache, rate
+// limiting and tracing only take effect after a restart, and the running
+// settings are kept for them. If the configuration cannot be applied, the
+// webhook keeps running with the previous one and the error is returned.
+func (h Hook) Reload(c conf.C) error {
+	prev := h.current()
+	changed := conf.Diff(&prev.conf, &c)
+	if len(changed) == 0 {
+		slog.LogAttrs(
+			context.Background(),
+			slog.LevelInfo,
+			"configuration unchanged",
+		)
+		return nil
+	}
+
+	var restart []string
+	for _, key := range changed {
+		if requiresRestart(key) {
+			restart = append(restart, key)
+		}
+	}
+	c.Hook.Listen = prev.conf.Hook.Listen
+	c.Hook.AdminListen = prev.conf.Hook.AdminListen
+	c.Hook.TLS = prev.conf.Hook.TLS
+	c.Delivery.Queue = prev.conf.Delivery.Queue
+	c.Delivery.Outbox = prev.conf.Delivery.Outbox
+	c.Delivery.DeadLetter = prev.conf.Delivery.DeadLetter
+	c.Delivery.Dedup = prev.conf.Delivery.Dedup
+	c.Delivery.RateLimit = prev.conf.Delivery.RateLimit
+	c.Tracing = prev.conf.Tracing
+
+	s, err := newState(c, prev)
+	if err != nil {
+		return err
+	}
+	if err := h.queue.Reload(c); err != nil {
+		return fmt.Errorf("failed to reload delivery queue: %w", err)
+	}
+	h.state.Store(s)
+	slog.SetDefault(newLogger(c.Hook.Log))
+
+	slog.LogAttrs(
+		context.Background(),
+		slog.LevelInfo,
+		"reloaded configuration",
+		slog.Any("changed", changed),
+	)
+	if len(restart) != 0 {
+		slog.LogAttrs(
+			context.Background(),
+			slog.LevelWarn,
+			"some configuration changes only take effect after a restart",
+			slog.Any("keys", restart),
+		)
+	}
+	return nil
+}
+
+func requiresRestart(key string) bool {
+	for _, k := range restartKeys {
+		if key == k || strings.HasPrefix(key, k+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package hook
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReload(t *testing.T) {
+	a := assert.New(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	load := func(yaml string) conf.C {
+		require.NoError(t, os.WriteFile(path, []byte(yaml+`
+ntfy:
+  baseUrl: https://ntfy.example.com
+  notification:
+    topic: alerts
+    title: title
+    description: description
+`), 0o600))
+		c, err := conf.New("--conf", path)
+		require.NoError(t, err)
+		return *c
+	}
+
+	h, err := New(load(""))
+	require.NoError(t, err)
+	defer h.queue.Shutdown(context.Background())
+	a.Nil(h.current().auth)
+	prev := h.current()
+
+	a.NoError(h.Reload(load("")))
+	a.Same(prev, h.current(), "unchanged configuration is not applied")
+
+	a.NoError(h.Reload(load(`
+hook:
+  listen: ":9999"
+  auth:
+    enable: true
+    username: alertfy
+    password: secret
+endpoints:
+  team:
+    notification:
+      topic: team
+`)))
+	s := h.current()
+	a.NotNil(s.auth)
+	_, ok := s.conf.Endpoint("team")
+	a.True(ok)
+	a.Equal(":5748", s.conf.Hook.Listen, "listen address changes need a restart")
+
+	a.Error(h.Reload(load(`
+hook:
+  listen: ":9999"
+endpoints:
+  team:
+    notifier: carrier-pigeon
+`)))
+	a.Same(s, h.current(), "configuration that cannot be applied is ignored")
+}
@@ -0,0 +1,144 @@
+package hook
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/murtaza-u/alertfy/internal/alert"
+	"github.com/murtaza-u/alertfy/internal/deadletter"
+	"github.com/murtaza-u/alertfy/internal/delivery"
+	"github.com/murtaza-u/alertfy/internal/ntfy"
+	"github.com/murtaza-u/alertfy/internal/route"
+
+	"github.com/labstack/echo/v4"
+)
+
+func (h Hook) listDeadLetters(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.deadLetters.List())
+}
+
+func (h Hook) getDeadLetter(c echo.Context) error {
+	e, err := h.deadLetters.Get(c.Param("id"))
+	if err != nil {
+		return deadLetterError(c, err)
+	}
+	return c.JSON(http.StatusOK, e)
+}
+
+func (h Hook) deleteDeadLetter(c echo.Context) error {
+	if err := h.deadLetters.Delete(c.Param("id")); err != nil {
+		return deadLetterError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h Hook) purgeDeadLetters(c echo.Context) error {
+	n, err := h.deadLetters.Purge()
+	if err != nil {
+		return deadLetterError(c, err)
+	}
+	slog.LogAttrs(
+		c.Request().Context(),
+		slog.LevelInfo,
+		"purged dead-letter store",
+		slog.Int("purged", n),
+	)
+	return c.JSON(http.StatusOK, map[string]int{"purged": n})
+}
+
+// replayDeadLetter renders the notification of a dead letter again using the
+// current configuration of its endpoint and route, and enqueues it for
+// delivery. The notification is rendered from the original payload, for the
+// alert with the fingerprint of the dead letter in "alert" mode, so that the
+// templates see the same data as on the first delivery. If the route fans notifications out to a list of topics and the
+// dead letter failed to be delivered to one of them, only the notification
+// for that topic is replayed. The entry is removed from the store
+// once the notification is enqueued.
+func (h Hook) replayDeadLetter(c echo.Context) error {
+	ctx := c.Request().Context()
+	e, err := h.deadLetters.Get(c.Param("id"))
+	if err != nil {
+		return deadLetterError(c, err)
+	}
+
+	n, ok := h.current().conf.Endpoint(e.Endpoint)
+	if !ok {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]string{
+			"error": fmt.Sprintf("endpoint %q is not configured", e.Endpoint),
+		})
+	}
+
+	var a *alert.Alert
+	labels := e.Payload.CommonLabels
+	if e.Fingerprint != "" {
+		found, ok := findAlert(e.Payload, e.Fingerprint)
+		if !ok {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]string{
+				"error": "alert not found in payload",
+			})
+		}
+		a, labels = &found, found.Labels
+	}
+	m, ok := route.Get(n, e.Route, labels)
+	if !ok {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]string{
+			"error": fmt.Sprintf("route %q no longer matches the alert", e.Route),
+		})
+	}
+
+	data, err := ntfy.RenderRoute(ctx, n, m, e.Payload, a)
+	if err != nil {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	jobs := fanOut(newJob(ctx, e.Endpoint, m, e.Payload, a), m, data)
+	if e.Notification != nil && len(m.Notification.Topics) != 0 {
+		jobs = slices.DeleteFunc(jobs, func(j delivery.Job) bool {
+			return j.Notification.Topic != e.Notification.Topic
+		})
+	}
+	if len(jobs) == 0 {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]string{
+			"error": "no topic of the route matches the alert",
+		})
+	}
+
+	if err := h.queue.Enqueue(jobs...); err != nil {
+		status := enqueueStatus(err)
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	if err := h.deadLetters.Delete(e.ID); err != nil {
+		return deadLetterError(c, err)
+	}
+
+	slog.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		"replayed dead letter",
+		slog.String("id", e.ID),
+		slog.String("fingerprint", e.Fingerprint),
+		slog.String("groupKey", e.GroupKey),
+	)
+	return c.NoContent(http.StatusAccepted)
+}
+
+func deadLetterError(c echo.Context, err error) error {
+	if errors.Is(err, deadletter.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	slog.LogAttrs(
+		c.Request().Context(),
+		slog.LevelError,
+		"dead-letter store operation failed",
+		slog.String("error", err.Error()),
+	)
+	return c.JSON(http.StatusInternalServerError, map[string]string{
+		"error": err.Error(),
+	})
+}
@@ -2,94 +2,253 @@ package hook
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/deadletter"
+	"github.com/murtaza-u/alertfy/internal/dedup"
+	"github.com/murtaza-u/alertfy/internal/delivery"
+	"github.com/murtaza-u/alertfy/internal/metrics"
 
 	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
 )
 
-const port = ":5748"
-
 // Hook represents a webhook object.
 type Hook struct {
-	conf      conf.C
-	startedAt time.Time
+	// state is replaced as a whole when the configuration is reloaded.
+	state       *atomic.Pointer[state]
+	queue       *delivery.Queue
+	deadLetters *deadletter.Store
+	dedup       *dedup.Cache
+	startedAt   time.Time
+
+	// tlsConfig is nil if TLS is disabled. clientCAs is nil unless client
+	// certificates are verified.
+	tlsConfig *tls.Config
+	clientCAs *reloader[*x509.CertPool]
 }
 
-// New initializes a webhook object with the provided configuration.
-func New(c conf.C) (*Hook, error) {
-	return &Hook{
-		conf:      c,
-		startedAt: time.Now(),
-	}, nil
+// state is the configuration of the webhook along with the parts of the
+// webhook created from it that can be replaced on reload.
+type state struct {
+	conf conf.C
+	// auth is nil if authentication is disabled. signature is nil if
+	// signature verification is disabled.
+	auth      *authenticator
+	signature *verifier
 }
 
-// Listen starts the webhook API server.
-func (h Hook) Listen() {
-	e := echo.New()
+// newState creates the state for the provided configuration. The signature
+// verifier of the previous state, if any, is kept if its configuration did
+// not change, so that replayed signatures are still detected.
+func newState(c conf.C, prev *state) (*state, error) {
+	s := &state{conf: c}
+	if c.Hook.Auth.Enable {
+		auth, err := newAuthenticator(c.Hook.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure authentication: %w", err)
+		}
+		s.auth = auth
+	}
+	if c.Hook.Signature.Enable {
+		if prev != nil && prev.signature != nil &&
+			prev.conf.Hook.Signature == c.Hook.Signature {
+			s.signature = prev.signature
+		} else {
+			s.signature = newVerifier(c.Hook.Signature)
+		}
+	}
+	return s, nil
+}
+
+// New initializes a webhook object with the provided configuration. It also
+// configures the default logger and starts the workers of the delivery queue.
+func New(c conf.C) (*Hook, error) {
+	h := &Hook{
+		state:     new(atomic.Pointer[state]),
+		startedAt: time.Now(),
+	}
 
 	// configure logger
-	slog.SetDefault(h.logger())
+	slog.SetDefault(newLogger(c.Hook.Log))
 
-	// setup basic auth middleware, if enabled
-	var middlewares []echo.MiddlewareFunc
-	if h.conf.Hook.Auth.Enable {
-		middlewares = append(middlewares, middleware.BasicAuth(h.basicAuth))
+	s, err := newState(c, nil)
+	if err != nil {
+		return nil, err
 	}
+	h.state.Store(s)
 
-	e.POST("/hook", h.serve, middlewares...)
-	e.GET("/health", h.health)
+	if c.Hook.TLS.Enable {
+		var err error
+		h.tlsConfig, h.clientCAs, err = newTLSConfig(c.Hook.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure TLS: %w", err)
+		}
+	}
+
+	dl := c.Delivery.DeadLetter
+	deadLetters, err := deadletter.New(dl.MaxEntries, dl.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dead-letter store: %w", err)
+	}
+	h.deadLetters = deadLetters
+
+	if dd := c.Delivery.Dedup; dd.TTL > 0 {
+		h.dedup, err = dedup.New(dd.TTL, dd.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open dedup cache: %w", err)
+		}
+	}
+
+	queue, err := delivery.NewQueue(c, deadLetters, h.dedup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create delivery queue: %w", err)
+	}
+	h.queue = queue
+
+	return h, nil
+}
+
+// Listen starts the webhook API server, along with the admin API server if a
+// separate admin listener is configured. On termination, it stops accepting
+// requests and drains the delivery queue within the termination grace period.
+func (h Hook) Listen() {
+	servers, e := h.servers()
 
 	ctx := context.Background()
 	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
 	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		err := e.Start(port)
-		if err != nil {
-			if errors.Is(err, http.ErrServerClosed) {
-				slog.Log(context.Background(), slog.LevelInfo, "shutting down")
-				return
+	for addr, srv := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var tlsConfig *tls.Config
+			if srv == e {
+				tlsConfig = h.tlsConfig
 			}
-			slog.LogAttrs(
-				context.Background(),
-				slog.LevelError,
-				"server terminated",
-				slog.String("error", err.Error()),
-			)
-			stop()
-		}
-	}()
+			err := start(srv, addr, tlsConfig)
+			if err != nil {
+				if errors.Is(err, http.ErrServerClosed) {
+					slog.LogAttrs(
+						context.Background(),
+						slog.LevelInfo,
+						"shutting down",
+						slog.String("listen", addr),
+					)
+					return
+				}
+				slog.LogAttrs(
+					context.Background(),
+					slog.LevelError,
+					"server terminated",
+					slog.String("listen", addr),
+					slog.String("error", err.Error()),
+				)
+				stop()
+			}
+		}()
+	}
 
 	// interrupt received
 	<-ctx.Done()
 
 	// graceful termination
 	ctx, cancel := context.WithCancel(context.Background())
-	if h.conf.Hook.TerminationGracePeriod != 0 {
-		ctx, cancel = context.WithTimeout(ctx, h.conf.Hook.TerminationGracePeriod)
+	if grace := h.current().conf.Hook.TerminationGracePeriod; grace != 0 {
+		ctx, cancel = context.WithTimeout(ctx, grace)
 	}
 	defer cancel()
-	if err := e.Shutdown(ctx); err != nil {
+	for addr, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			slog.LogAttrs(
+				ctx,
+				slog.LevelError,
+				"forcefully shutting down",
+				slog.String("listen", addr),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+
+	// drain the delivery queue within what is left of the grace period
+	if err := h.queue.Shutdown(ctx); err != nil {
 		slog.LogAttrs(
 			ctx,
 			slog.LevelError,
-			"forcefully shutting down",
+			"failed to drain delivery queue",
+			slog.String("error", err.Error()),
+		)
+	}
+	if err := h.dedup.Close(); err != nil {
+		slog.LogAttrs(
+			ctx,
+			slog.LevelError,
+			"failed to persist dedup cache",
 			slog.String("error", err.Error()),
 		)
 	}
 
 	wg.Wait()
 }
+
+// servers returns the servers to start, keyed by their listen address, along
+// with the server listening on `hook.listen`.
+func (h Hook) servers() (map[string]*echo.Echo, *echo.Echo) {
+	c := h.current().conf
+	e := echo.New()
+	servers := map[string]*echo.Echo{c.Hook.Listen: e}
+
+	// authentication and signature verification can be enabled on reload,
+	// so their middlewares are always set up
+	middlewares := []echo.MiddlewareFunc{h.authenticate}
+
+	hookMiddlewares := []echo.MiddlewareFunc{countRequests}
+	if h.clientCAs != nil {
+		hookMiddlewares = append(hookMiddlewares, h.clientCert)
+	}
+	// a valid signature stands in for credentials, so it is verified first
+	hookMiddlewares = append(hookMiddlewares, h.verifySignature)
+	hookMiddlewares = append(hookMiddlewares, middlewares...)
+	e.POST("/hook", h.serve, hookMiddlewares...)
+	e.POST("/hook/:name", h.serve, hookMiddlewares...)
+
+	admin := e
+	apiMiddlewares := middlewares
+	if addr := c.Hook.AdminListen; addr != "" {
+		admin = echo.New()
+		admin.HideBanner = true
+		servers[addr] = admin
+	} else {
+		// anyone able to send alerts could otherwise replay or purge dead
+		// letters
+		apiMiddlewares = append([]echo.MiddlewareFunc{h.requireAuth}, middlewares...)
+	}
+	admin.GET("/health", h.health)
+	admin.GET("/metrics", echo.WrapHandler(metrics.Handler()))
+
+	api := admin.Group("/api", apiMiddlewares...)
+	api.GET("/deadletters", h.listDeadLetters)
+	api.DELETE("/deadletters", h.purgeDeadLetters)
+	api.GET("/deadletters/:id", h.getDeadLetter)
+	api.DELETE("/deadletters/:id", h.deleteDeadLetter)
+	api.POST("/deadletters/:id/replay", h.replayDeadLetter)
+	return servers, e
+}
+
+// current returns the current state of the webhook.
+func (h Hook) current() *state {
+	return h.state.Load()
+}
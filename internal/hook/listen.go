@@ -0,0 +1,50 @@
+package hook
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+
+	"github.com/labstack/echo/v4"
+)
+
+// start serves the API server on the provided address until it is shut down.
+// If a TLS configuration is provided, the server serves HTTPS.
+func start(e *echo.Echo, addr string, tlsConfig *tls.Config) error {
+	l, err := listen(addr)
+	if err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		l = tls.NewListener(l, tlsConfig)
+	}
+	e.Listener = l
+	return e.Start("")
+}
+
+// listen creates a listener for the provided address, which is either a TCP
+// address or the path of a Unix socket prefixed by "unix://". A socket file
+// left behind by a previous run is removed, unless a server still accepts
+// connections on it.
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, conf.UnixSocketPrefix)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		conn, err := net.DialTimeout("unix", path, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil, errors.New("socket " + path + " is already in use")
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
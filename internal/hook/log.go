@@ -1,13 +1,17 @@
 package hook
 
 import (
+	"context"
 	"log/slog"
 	"os"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
 )
 
-func (h Hook) logger() *slog.Logger {
+// newLogger creates a logger with the configured level and format.
+func newLogger(c conf.Log) *slog.Logger {
 	var level slog.Level
-	switch h.conf.Hook.Log.Level {
+	switch c.Level {
 	case "debug":
 		level = slog.LevelDebug
 	case "warn":
@@ -18,8 +22,39 @@ func (h Hook) logger() *slog.Logger {
 		level = slog.LevelInfo
 	}
 	opt := &slog.HandlerOptions{Level: level}
-	if h.conf.Hook.Log.Format == "json" {
-		return slog.New(slog.NewJSONHandler(os.Stderr, opt))
+	if c.Format == "json" {
+		return slog.New(contextHandler{slog.NewJSONHandler(os.Stderr, opt)})
 	}
-	return slog.New(slog.NewTextHandler(os.Stderr, opt))
+	return slog.New(contextHandler{slog.NewTextHandler(os.Stderr, opt)})
+}
+
+type logAttrsKey struct{}
+
+// withLogAttrs returns a copy of the context carrying attributes that are
+// added to every record logged with the context.
+func withLogAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	prev, _ := ctx.Value(logAttrsKey{}).([]slog.Attr)
+	return context.WithValue(ctx, logAttrsKey{},
+		append(prev[:len(prev):len(prev)], attrs...))
+}
+
+// contextHandler adds the attributes carried by the context of a record to
+// the record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(logAttrsKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
 }
@@ -1,17 +1,43 @@
 package hook
 
 import (
-	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/murtaza-u/alertfy/internal/metrics"
 
 	"github.com/labstack/echo/v4"
 )
 
-func (h Hook) basicAuth(uname, pswd string, c echo.Context) (bool, error) {
-	auth := h.conf.Hook.Auth
-	uMatch := subtle.ConstantTimeCompare([]byte(uname), []byte(auth.Username))
-	pMatch := subtle.ConstantTimeCompare([]byte(pswd), []byte(auth.Password))
-	if uMatch == 1 && pMatch == 1 {
-		return true, nil
+// countRequests counts the requests handled by the next handler by HTTP status
+// code.
+func countRequests(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		err := next(c)
+		code := c.Response().Status
+		if err != nil {
+			code = http.StatusInternalServerError
+			var he *echo.HTTPError
+			if errors.As(err, &he) {
+				code = he.Code
+			}
+		}
+		metrics.WebhookRequests.WithLabelValues(strconv.Itoa(code)).Inc()
+		return err
+	}
+}
+
+// requireAuth rejects the requests while authentication is disabled. It
+// protects the admin API when it is served on the webhook listener.
+func (h Hook) requireAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if h.current().auth == nil {
+			return c.JSON(http.StatusForbidden, map[string]string{
+				"error": "the admin API requires `hook.auth` to be enabled " +
+					"unless it is served on `hook.adminListen`",
+			})
+		}
+		return next(c)
 	}
-	return false, nil
 }
@@ -0,0 +1,86 @@
+package hook
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// reloader caches a value loaded from a set of files, and loads it again
+// whenever the modification time of any of the files changes. If loading
+// fails, the previously loaded value is kept.
+type reloader[T any] struct {
+	name  string
+	files []string
+	load  func() (T, error)
+
+	mu       sync.Mutex
+	value    T
+	modTimes []time.Time
+}
+
+// newReloader creates a reloader and loads the value for the first time.
+func newReloader[T any](name string, load func() (T, error), files ...string) (*reloader[T], error) {
+	r := &reloader[T]{name: name, files: files, load: load}
+	modTimes, err := r.stat()
+	if err != nil {
+		return nil, err
+	}
+	if r.value, err = load(); err != nil {
+		return nil, err
+	}
+	r.modTimes = modTimes
+	return r, nil
+}
+
+// get returns the value, loading it again first if any of the files changed.
+func (r *reloader[T]) get() T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTimes, err := r.stat()
+	if err == nil && slices.EqualFunc(modTimes, r.modTimes, time.Time.Equal) {
+		return r.value
+	}
+	if err == nil {
+		var v T
+		v, err = r.load()
+		if err == nil {
+			r.value, r.modTimes = v, modTimes
+			slog.LogAttrs(
+				context.Background(),
+				slog.LevelInfo,
+				"reloaded "+r.name,
+				slog.Any("files", r.files),
+			)
+			return r.value
+		}
+	}
+	slog.LogAttrs(
+		context.Background(),
+		slog.LevelError,
+		"failed to reload "+r.name+". Keeping the previous one",
+		slog.Any("files", r.files),
+		slog.String("error", err.Error()),
+	)
+	// do not retry until the files change again
+	if modTimes != nil {
+		r.modTimes = modTimes
+	}
+	return r.value
+}
+
+func (r *reloader[T]) stat() ([]time.Time, error) {
+	modTimes := make([]time.Time, len(r.files))
+	for i, f := range r.files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[i] = fi.ModTime()
+	}
+	return modTimes, nil
+}
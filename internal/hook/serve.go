@@ -1,27 +1,63 @@
 package hook
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/murtaza-u/alertfy/internal/alert"
+	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/deadletter"
+	"github.com/murtaza-u/alertfy/internal/dedup"
+	"github.com/murtaza-u/alertfy/internal/delivery"
+	"github.com/murtaza-u/alertfy/internal/metrics"
 	"github.com/murtaza-u/alertfy/internal/ntfy"
+	"github.com/murtaza-u/alertfy/internal/route"
+	"github.com/murtaza-u/alertfy/internal/tracing"
 
 	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-type request struct {
-	Receiver    string        `json:"receiver"`
-	Status      string        `json:"status"`
-	Alerts      []alert.Alert `json:"alerts"`
-	ExternalURL string        `json:"externalURL"`
-}
+var tracer = tracing.Tracer("hook")
 
 func (h Hook) serve(c echo.Context) error {
-	req := new(request)
+	ctx := otel.GetTextMapPropagator().Extract(
+		c.Request().Context(),
+		propagation.HeaderCarrier(c.Request().Header),
+	)
+	ctx, span := tracer.Start(ctx, "Hook.serve",
+		trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	endpoint := c.Param("name")
+	n, ok := h.current().conf.Endpoint(endpoint)
+	if !ok {
+		span.SetStatus(codes.Error, "unknown endpoint")
+		slog.LogAttrs(
+			ctx,
+			slog.LevelWarn,
+			"received request for unknown endpoint",
+			slog.String("endpoint", endpoint),
+			slog.Int("status", http.StatusNotFound),
+		)
+		return c.NoContent(http.StatusNotFound)
+	}
+	ctx = withLogAttrs(ctx, slog.String("endpoint", endpointLabel(endpoint)))
+	span.SetAttributes(attribute.String("alertfy.endpoint", endpointLabel(endpoint)))
+
+	req := new(alert.Webhook)
 	if err := c.Bind(req); err != nil {
+		span.SetStatus(codes.Error, "failed to parse request body")
 		slog.LogAttrs(
-			c.Request().Context(),
+			ctx,
 			slog.LevelError,
 			"failed to parse request body",
 			slog.Int("status", http.StatusBadRequest),
@@ -29,86 +65,205 @@ func (h Hook) serve(c echo.Context) error {
 		return c.NoContent(http.StatusBadRequest)
 	}
 
+	span.SetAttributes(
+		attribute.String("alertmanager.receiver", req.Receiver),
+		attribute.String("alertmanager.group_key", req.GroupKey),
+		attribute.String("alertmanager.status", req.Status),
+		attribute.Int("alertmanager.alerts", len(req.Alerts)),
+	)
+
 	if len(req.Alerts) == 0 {
+		span.SetStatus(codes.Error, "received request with zero alerts")
 		slog.LogAttrs(
-			c.Request().Context(),
+			ctx,
 			slog.LevelWarn,
 			"received request with zero alerts",
 			slog.String("receiver", req.Receiver),
 			slog.String("globalStatus", req.Status),
+			slog.String("groupKey", req.GroupKey),
 			slog.String("externalURL", req.ExternalURL),
 		)
 		return c.NoContent(http.StatusBadRequest)
 	}
 
-	for _, alert := range req.Alerts {
+	for _, a := range req.Alerts {
+		metrics.AlertsReceived.WithLabelValues(endpointLabel(endpoint), a.Status).Inc()
 		slog.LogAttrs(
-			c.Request().Context(),
+			ctx,
 			slog.LevelDebug,
 			"received alert",
-			slog.String("fingerprint", alert.Fingerprint),
+			slog.String("fingerprint", a.Fingerprint),
 			slog.String("receiver", req.Receiver),
-			slog.String("status", alert.Status),
-			slog.Time("startsAt", alert.StartsAt),
-			slog.Time("endsAt", alert.EndsAt),
-			slog.String("generatorURL", alert.GeneratorURL),
-			slog.String("labels", formatLabels(alert.Labels)),
-			slog.String("annotations", formatLabels(alert.Annotations)),
+			slog.String("groupKey", req.GroupKey),
+			slog.String("status", a.Status),
+			slog.Time("startsAt", a.StartsAt),
+			slog.Time("endsAt", a.EndsAt),
+			slog.String("generatorURL", a.GeneratorURL),
+			slog.String("labels", formatLabels(a.Labels)),
+			slog.String("annotations", formatLabels(a.Annotations)),
 		)
-
-		err := h.forwardAlert(c, alert)
-		if err != nil {
-			return err
-		}
 	}
 
-	return c.NoContent(http.StatusAccepted)
-}
-
-func (h Hook) forwardAlert(c echo.Context, alert alert.Alert) error {
-	p := ntfy.NewParser(h.conf.Ntfy)
-	data := p.Parse(c.Request().Context(), alert)
-	if data == nil {
+	jobs, err := h.render(ctx, endpoint, n, *req)
+	if err != nil {
+		tracing.Fail(span, err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
+	span.SetAttributes(attribute.Int("alertfy.notifications", len(jobs)))
 
-	req, err := ntfy.NewRequest(c.Request().Context(), ntfy.RequestData{
-		Notification: *data,
-		BasicAuth:    h.conf.Ntfy.Auth,
-	})
-	if err != nil {
+	if err := h.queue.Enqueue(jobs...); err != nil {
+		// the notifications were not sent after all
+		for _, j := range jobs {
+			h.dedup.Forget(j.Dedup)
+		}
+		status := enqueueStatus(err)
+		tracing.Fail(span, err)
 		slog.LogAttrs(
-			c.Request().Context(),
+			ctx,
 			slog.LevelError,
-			"failed to create http request. Aborting",
-			slog.String("fingerprint", alert.Fingerprint),
+			"failed to enqueue notifications",
+			slog.String("receiver", req.Receiver),
+			slog.String("groupKey", req.GroupKey),
+			slog.Int("notifications", len(jobs)),
+			slog.Int("status", status),
 			slog.String("error", err.Error()),
 		)
-		return c.NoContent(http.StatusInternalServerError)
+		return c.NoContent(status)
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+// render renders the notifications for the provided webhook payload according
+// to the notification mode of the endpoint it was received on, configured by
+// n. A notification is rendered for every route matched by an alert, or by the
+// common labels of the group in "group" mode, and every topic of the route.
+// Notifications that fail to render are moved to the dead-letter store, while
+// duplicates of recently sent notifications are dropped. An error is returned
+// only if moving a notification to the dead-letter store fails, in which case
+// it has already been logged.
+func (h Hook) render(ctx context.Context, endpoint string, n conf.Ntfy, w alert.Webhook) ([]delivery.Job, error) {
+	var jobs []delivery.Job
+	for _, r := range ntfy.Render(ctx, n, w) {
+		j := newJob(ctx, endpoint, r.Route, w, r.Alert)
+		if r.Err != nil {
+			if err := h.deadLetter(ctx, j, r.Err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, j := range fanOut(j, r.Route, r.Notifications) {
+			if !h.suppressed(ctx, j) {
+				jobs = append(jobs, j)
+			}
+		}
 	}
+	return jobs, nil
+}
 
-	resp, err := http.DefaultClient.Do(req)
+// suppressed reports whether an identical notification was sent within the
+// dedup window, in which case the job must be dropped. Otherwise, the job is
+// recorded as sent.
+func (h Hook) suppressed(ctx context.Context, j delivery.Job) bool {
+	age, ok := h.dedup.Seen(j.Dedup)
+	if !ok {
+		return false
+	}
+	metrics.NotificationsSuppressed.WithLabelValues("duplicate").Inc()
+	reason := fmt.Sprintf("identical notification for the group sent %s ago",
+		age.Round(time.Second))
+	if j.Fingerprint != "" {
+		reason = fmt.Sprintf("identical %s notification sent %s ago",
+			j.Dedup.State, age.Round(time.Second))
+	}
+	slog.LogAttrs(
+		ctx,
+		slog.LevelDebug,
+		"duplicate notification suppressed",
+		j.LogAttr(),
+		slog.String("reason", reason),
+		slog.Duration("ttl", h.current().conf.Delivery.Dedup.TTL),
+	)
+	return true
+}
+
+// newJob returns the job, without the notification, for a single alert of the
+// webhook payload, or for the whole group if a is nil, received on the
+// provided endpoint and matching the route.
+func newJob(ctx context.Context, endpoint string, m route.Match, w alert.Webhook, a *alert.Alert) delivery.Job {
+	j := delivery.Job{
+		Endpoint:     endpoint,
+		Route:        m.ID,
+		GroupKey:     w.GroupKey,
+		Payload:      w,
+		Dedup:        dedup.Key{ID: dedupID(endpoint, m.ID, "group:"+w.GroupKey), State: groupState(w)},
+		TraceContext: tracing.Inject(ctx),
+	}
+	if a != nil {
+		j.Fingerprint = a.Fingerprint
+		j.Dedup = dedup.Key{ID: dedupID(endpoint, m.ID, a.Fingerprint), State: a.Status}
+	}
+	return j
+}
+
+// fanOut returns a copy of the job for every rendered notification. If the
+// route sends notifications to a list of topics, the topic is part of the
+// dedup ID so that each topic is deduplicated independently.
+func fanOut(j delivery.Job, m route.Match, data []ntfy.Data) []delivery.Job {
+	jobs := make([]delivery.Job, len(data))
+	for i, d := range data {
+		jobs[i] = j
+		jobs[i].Notification = d
+		if len(m.Notification.Topics) != 0 {
+			jobs[i].Dedup.ID += "#" + d.Topic
+		}
+	}
+	return jobs
+}
+
+// deadLetter records a job whose notification failed to render in the
+// dead-letter store.
+func (h Hook) deadLetter(ctx context.Context, j delivery.Job, cause error) error {
+	e, err := h.deadLetters.Add(deadletter.Entry{
+		Endpoint:    j.Endpoint,
+		Route:       j.Route,
+		Fingerprint: j.Fingerprint,
+		GroupKey:    j.GroupKey,
+		Payload:     j.Payload,
+		Error:       cause.Error(),
+	})
 	if err != nil {
 		slog.LogAttrs(
-			c.Request().Context(),
+			ctx,
 			slog.LevelError,
-			"failed to forward request to ntfy server. Aborting",
-			slog.String("fingerprint", alert.Fingerprint),
+			"failed to add notification to dead-letter store",
+			slog.String("fingerprint", j.Fingerprint),
+			slog.String("groupKey", j.GroupKey),
 			slog.String("error", err.Error()),
 		)
-		return c.NoContent(http.StatusInternalServerError)
+		return err
 	}
+	slog.LogAttrs(
+		ctx,
+		slog.LevelWarn,
+		"notification moved to dead-letter store",
+		slog.String("fingerprint", j.Fingerprint),
+		slog.String("groupKey", j.GroupKey),
+		slog.String("id", e.ID),
+	)
+	return nil
+}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		slog.LogAttrs(
-			c.Request().Context(),
-			slog.LevelError,
-			"non-2XX status code received from ntfy server. Aborting",
-			slog.String("fingerprint", alert.Fingerprint),
-			slog.String("status", resp.Status),
-		)
-		return c.NoContent(http.StatusInternalServerError)
+// enqueueStatus returns the HTTP status code to respond with when enqueuing
+// notifications fails. Batches too large for the queue are rejected with a
+// client error, so that Alertmanager does not retry them.
+func enqueueStatus(err error) int {
+	if errors.Is(err, delivery.ErrBatchTooLarge) {
+		return http.StatusRequestEntityTooLarge
 	}
-
-	return nil
+	if errors.Is(err, delivery.ErrQueueFull) ||
+		errors.Is(err, delivery.ErrQueueClosed) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
 }
@@ -0,0 +1,188 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/murtaza-u/alertfy/internal/alert"
+	"github.com/murtaza-u/alertfy/internal/conf"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestHook creates a webhook from the provided YAML configuration. The
+// queue is drained when the test ends.
+func newTestHook(t *testing.T, yaml string) *Hook {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(yaml), 0o600))
+	c, err := conf.New("--conf", path)
+	require.NoError(t, err)
+	h, err := New(*c)
+	require.NoError(t, err)
+	t.Cleanup(func() { h.queue.Shutdown(context.Background()) })
+	return h
+}
+
+// post sends the webhook payload made of the provided alerts to the handler
+// and returns the status code of the response.
+func post(t *testing.T, handler http.Handler, target string, alerts ...alert.Alert) int {
+	t.Helper()
+	body, err := json.Marshal(alert.Webhook{
+		Status:   "firing",
+		GroupKey: "group",
+		Alerts:   alerts,
+	})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestServeQueueFull(t *testing.T) {
+	a := assert.New(t)
+	// without workers, nothing is drained from the queue
+	h := newTestHook(t, `
+ntfy:
+  baseUrl: http://127.0.0.1:0
+  notification:
+    topic: alerts
+    title: title
+    description: description
+delivery:
+  queue:
+    size: 2
+    workers: 0
+`)
+	e := echo.New()
+	e.POST("/hook", h.serve)
+
+	firing := func(fingerprint string) alert.Alert {
+		return alert.Alert{Status: "firing", Fingerprint: fingerprint}
+	}
+	a.Equal(http.StatusRequestEntityTooLarge,
+		post(t, e, "/hook", firing("a"), firing("b"), firing("c")),
+		"a payload larger than the queue can never be enqueued")
+	a.Equal(http.StatusAccepted, post(t, e, "/hook", firing("a")))
+	a.Equal(http.StatusServiceUnavailable, post(t, e, "/hook", firing("b"), firing("c")))
+	a.Equal(http.StatusAccepted, post(t, e, "/hook", firing("b")))
+	a.Equal(http.StatusServiceUnavailable, post(t, e, "/hook", firing("c")))
+}
+
+func TestServeResendAfterFailure(t *testing.T) {
+	a := assert.New(t)
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if hits.Add(1) == 1 {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		},
+	))
+	defer srv.Close()
+
+	h := newTestHook(t, `
+ntfy:
+  baseUrl: `+srv.URL+`
+  notification:
+    topic: alerts
+    title: title
+    description: description
+delivery:
+  queue:
+    workers: 1
+  retry:
+    maxAttempts: 1
+  dedup:
+    ttl: 1h
+`)
+	e := echo.New()
+	e.POST("/hook", h.serve)
+	firing := alert.Alert{Status: "firing", Fingerprint: "a"}
+
+	a.Equal(http.StatusAccepted, post(t, e, "/hook", firing))
+	require.Eventually(t, func() bool { return len(h.deadLetters.List()) == 1 },
+		time.Second, 10*time.Millisecond)
+
+	a.Equal(http.StatusAccepted, post(t, e, "/hook", firing))
+	require.Eventually(t, func() bool { return hits.Load() == 2 },
+		time.Second, 10*time.Millisecond, "the resend of a failed notification is sent")
+
+	a.Equal(http.StatusAccepted, post(t, e, "/hook", firing))
+	require.NoError(t, h.queue.Shutdown(context.Background()))
+	a.EqualValues(2, hits.Load(), "the resend of a delivered notification is suppressed")
+}
+
+func TestReplayDeadLetter(t *testing.T) {
+	a := assert.New(t)
+	var mu sync.Mutex
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			defer mu.Unlock()
+			bodies = append(bodies, string(body))
+			if len(bodies) <= 2 {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		},
+	))
+	defer srv.Close()
+
+	h := newTestHook(t, `
+ntfy:
+  baseUrl: `+srv.URL+`
+  notification:
+    topic: alerts
+    title: title
+    description: |
+      {{ .Fingerprint }} of {{ len .Alerts }}/{{ .TotalAlerts }}:
+      {{- range .Alerts.Firing }} {{ .Fingerprint }}{{ end }}
+delivery:
+  queue:
+    workers: 1
+  retry:
+    maxAttempts: 1
+`)
+	e := echo.New()
+	e.POST("/hook", h.serve)
+	e.POST("/api/deadletters/:id/replay", h.replayDeadLetter)
+
+	a.Equal(http.StatusAccepted, post(t, e, "/hook",
+		alert.Alert{Status: "firing", Fingerprint: "a"},
+		alert.Alert{Status: "firing", Fingerprint: "b"},
+	))
+	require.Eventually(t, func() bool { return len(h.deadLetters.List()) == 2 },
+		time.Second, 10*time.Millisecond)
+	for _, entry := range h.deadLetters.List() {
+		a.Len(entry.Payload.Alerts, 2, "the original payload is kept")
+	}
+
+	entry := h.deadLetters.List()[0]
+	req := httptest.NewRequest(http.MethodPost, "/api/deadletters/"+entry.ID+"/replay", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	a.Equal(http.StatusAccepted, rec.Code)
+	require.NoError(t, h.queue.Shutdown(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, bodies, 3)
+	a.Equal(entry.Fingerprint+" of 2/2: a b", bodies[2])
+	a.Contains(bodies[:2], bodies[2], "the replay renders as the first delivery")
+}
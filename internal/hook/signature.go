@@ -0,0 +1,139 @@
+package hook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+
+	"github.com/labstack/echo/v4"
+)
+
+// signedKey is the key of the echo context value set on requests with a valid
+// signature, which need no other credentials.
+const signedKey = "signed"
+
+// verifier verifies HMAC-SHA256 request signatures. Signatures are
+// remembered until their timestamp leaves the tolerance, so that a signed
+// request cannot be replayed.
+type verifier struct {
+	conf conf.Signature
+	now  func() time.Time
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func newVerifier(c conf.Signature) *verifier {
+	return &verifier{
+		conf: c,
+		now:  time.Now,
+		seen: make(map[string]time.Time),
+	}
+}
+
+// sign returns the signature of the body for the provided timestamp.
+func (v *verifier) sign(timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(v.conf.Secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// signed reports whether a request with the provided headers carries a
+// signature, valid or not.
+func (v *verifier) signed(header http.Header) bool {
+	return header.Get(v.conf.Header) != "" || header.Get(v.conf.TimestampHeader) != ""
+}
+
+// verify checks the signature of a request with the provided headers and raw
+// body.
+func (v *verifier) verify(header http.Header, body []byte) error {
+	timestamp := header.Get(v.conf.TimestampHeader)
+	signature := header.Get(v.conf.Header)
+	if timestamp == "" || signature == "" {
+		return errors.New("request is not signed")
+	}
+	secs, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+	at := time.Unix(secs, 0)
+	now := v.now()
+	if at.Before(now.Add(-v.conf.Tolerance)) || at.After(now.Add(v.conf.Tolerance)) {
+		return errors.New("timestamp is outside the tolerance")
+	}
+
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || !hmac.Equal(got, v.sign(timestamp, body)) {
+		return errors.New("invalid signature")
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for sig, expiry := range v.seen {
+		if now.After(expiry) {
+			delete(v.seen, sig)
+		}
+	}
+	key := string(got)
+	if _, ok := v.seen[key]; ok {
+		return errors.New("signature has already been used")
+	}
+	v.seen[key] = at.Add(v.conf.Tolerance)
+	return nil
+}
+
+// verifySignature rejects requests with an invalid signature before their
+// body is parsed, if signature verification is enabled. A valid signature
+// authenticates the request on its own. Unsigned requests, such as the ones
+// of Alertmanager, are left to authentication if enabled, and rejected
+// otherwise.
+func (h Hook) verifySignature(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		s := h.current()
+		req := c.Request()
+		if s.signature == nil || (s.auth != nil && !s.signature.signed(req.Header)) {
+			return next(c)
+		}
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			slog.LogAttrs(
+				req.Context(),
+				slog.LevelError,
+				"failed to read request body",
+				slog.Int("status", http.StatusBadRequest),
+				slog.String("error", err.Error()),
+			)
+			return c.NoContent(http.StatusBadRequest)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		if err := s.signature.verify(req.Header, body); err != nil {
+			slog.LogAttrs(
+				req.Context(),
+				slog.LevelWarn,
+				"rejected request with missing or invalid signature",
+				slog.String("remoteAddr", req.RemoteAddr),
+				slog.Int("status", http.StatusUnauthorized),
+				slog.String("error", err.Error()),
+			)
+			return c.NoContent(http.StatusUnauthorized)
+		}
+		ctx := withLogAttrs(req.Context(), slog.String("credential", "signature"))
+		c.SetRequest(req.WithContext(ctx))
+		c.Set(signedKey, true)
+		return next(c)
+	}
+}
@@ -0,0 +1,128 @@
+package hook
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	a := assert.New(t)
+	now := time.Unix(1700000000, 0)
+	v := newVerifier(conf.Signature{
+		Enable:          true,
+		Secret:          "s3cr3t",
+		Header:          "X-Alertfy-Signature",
+		TimestampHeader: "X-Alertfy-Timestamp",
+		Tolerance:       5 * time.Minute,
+	})
+	v.now = func() time.Time { return now }
+
+	body := []byte(`{"alerts":[]}`)
+	signed := func(at time.Time, body []byte) http.Header {
+		ts := strconv.FormatInt(at.Unix(), 10)
+		h := http.Header{}
+		h.Set("X-Alertfy-Timestamp", ts)
+		h.Set("X-Alertfy-Signature", "sha256="+hex.EncodeToString(v.sign(ts, body)))
+		return h
+	}
+
+	h := signed(now, body)
+	a.NoError(v.verify(h, body))
+	a.Error(v.verify(h, body), "replayed signature")
+
+	a.NoError(v.verify(signed(now.Add(-time.Minute), body), body))
+	a.Error(v.verify(signed(now.Add(-10*time.Minute), body), body), "stale")
+	a.Error(v.verify(signed(now.Add(10*time.Minute), body), body), "in the future")
+	a.Error(v.verify(signed(now.Add(-2*time.Minute), body), []byte(`{}`)), "tampered body")
+	a.Error(v.verify(http.Header{}, body), "unsigned")
+
+	h = signed(now.Add(-3*time.Minute), body)
+	h.Set("X-Alertfy-Signature", hex.EncodeToString(v.sign("0", body)))
+	a.Error(v.verify(h, body), "signature for another timestamp")
+
+	// signatures are forgotten once their timestamp leaves the tolerance
+	now = now.Add(10 * time.Minute)
+	a.NoError(v.verify(signed(now, body), body))
+	a.Len(v.seen, 1)
+}
+
+func TestVerifySignature(t *testing.T) {
+	const ntfy = `
+ntfy:
+  baseUrl: http://127.0.0.1:0
+  notification:
+    topic: alerts
+    title: title
+    description: description
+delivery:
+  queue:
+    workers: 0
+hook:
+  signature:
+    enable: true
+    secret: s3cr3t
+`
+	const auth = `
+  auth:
+    enable: true
+    credentials:
+      - name: alertmanager
+        token: secret
+`
+	v := newVerifier(conf.Signature{Secret: "s3cr3t"})
+	body := []byte(`{"status":"firing","alerts":[{"status":"firing","fingerprint":"a"}]}`)
+
+	tests := []struct {
+		name      string
+		auth      bool
+		signature string
+		token     string
+		want      int
+	}{
+		{name: "signed with credentials", auth: true, signature: "valid", token: "secret", want: http.StatusAccepted},
+		{name: "signed without credentials", auth: true, signature: "valid", want: http.StatusAccepted},
+		{name: "unsigned with credentials", auth: true, token: "secret", want: http.StatusAccepted},
+		{name: "unsigned without credentials", auth: true, want: http.StatusUnauthorized},
+		{name: "unsigned with wrong credentials", auth: true, token: "wrong", want: http.StatusUnauthorized},
+		{name: "invalid signature with credentials", auth: true, signature: "00", token: "secret", want: http.StatusUnauthorized},
+		{name: "signed without auth", signature: "valid", want: http.StatusAccepted},
+		{name: "unsigned without auth", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yaml := ntfy
+			if tt.auth {
+				yaml += auth
+			}
+			_, e := newTestHook(t, yaml).servers()
+
+			req := httptest.NewRequest(http.MethodPost, "/hook", bytes.NewReader(body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if tt.signature != "" {
+				ts := strconv.FormatInt(time.Now().Unix(), 10)
+				signature := tt.signature
+				if signature == "valid" {
+					signature = hex.EncodeToString(v.sign(ts, body))
+				}
+				req.Header.Set("X-Alertfy-Timestamp", ts)
+				req.Header.Set("X-Alertfy-Signature", signature)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
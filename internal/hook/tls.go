@@ -0,0 +1,111 @@
+package hook
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+
+	"github.com/labstack/echo/v4"
+)
+
+// newTLSConfig creates the TLS configuration for the webhook API server. The
+// server certificate is loaded again whenever it is rotated on disk. If a
+// client CA is configured, client certificates are requested but verified by
+// the clientCert middleware instead of during the handshake, so that rejected
+// clients can be logged along with their certificate subject.
+func newTLSConfig(c conf.TLS) (*tls.Config, *reloader[*x509.CertPool], error) {
+	keyPair, err := newReloader("server certificate", func() (*tls.Certificate, error) {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &cert, nil
+	}, c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading server certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"http/1.1"},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return keyPair.get(), nil
+		},
+	}
+	if c.ClientCAFile == "" {
+		return cfg, nil, nil
+	}
+
+	clientCAs, err := newReloader("client CA", func() (*x509.CertPool, error) {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found")
+		}
+		return pool, nil
+	}, c.ClientCAFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading client CA: %w", err)
+	}
+	cfg.ClientAuth = tls.RequestClientCert
+	return cfg, clientCAs, nil
+}
+
+// clientCert rejects requests that do not present a client certificate signed
+// by one of the configured client CAs.
+func (h Hook) clientCert(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+			slog.LogAttrs(
+				req.Context(),
+				slog.LevelWarn,
+				"rejected request without client certificate",
+				slog.String("remoteAddr", req.RemoteAddr),
+				slog.Int("status", http.StatusUnauthorized),
+			)
+			return c.NoContent(http.StatusUnauthorized)
+		}
+
+		certs := req.TLS.PeerCertificates
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         h.clientCAs.get(),
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		if err != nil {
+			slog.LogAttrs(
+				req.Context(),
+				slog.LevelWarn,
+				"rejected request with untrusted client certificate",
+				slog.String("remoteAddr", req.RemoteAddr),
+				slog.String("subject", certs[0].Subject.String()),
+				slog.String("issuer", certs[0].Issuer.String()),
+				slog.Int("status", http.StatusForbidden),
+				slog.String("error", err.Error()),
+			)
+			return c.NoContent(http.StatusForbidden)
+		}
+
+		slog.LogAttrs(
+			req.Context(),
+			slog.LevelDebug,
+			"accepted client certificate",
+			slog.String("subject", certs[0].Subject.String()),
+		)
+		return next(c)
+	}
+}
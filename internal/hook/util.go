@@ -1,8 +1,14 @@
 package hook
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"sort"
 	"strings"
+
+	"github.com/murtaza-u/alertfy/internal/alert"
+	"github.com/murtaza-u/alertfy/internal/conf"
 )
 
 func formatLabels(m map[string]string) string {
@@ -12,3 +18,48 @@ func formatLabels(m map[string]string) string {
 	}
 	return strings.TrimRight(s, ",")
 }
+
+// findAlert returns the alert with the provided fingerprint from the webhook
+// payload.
+func findAlert(w alert.Webhook, fingerprint string) (alert.Alert, bool) {
+	for _, a := range w.Alerts {
+		if a.Fingerprint == fingerprint {
+			return a, true
+		}
+	}
+	return alert.Alert{}, false
+}
+
+// dedupID returns the dedup ID of a notification rendered on the provided
+// endpoint and route, so that the notifications for the same alert received on
+// different endpoints or matching different routes are not deduplicated.
+func dedupID(endpoint, route, id string) string {
+	if route != "" {
+		id += "@" + route
+	}
+	if endpoint == "" {
+		return id
+	}
+	return endpoint + "/" + id
+}
+
+// groupState returns a digest of the fingerprint and status of every alert in
+// the webhook payload, which changes whenever the group changes.
+func groupState(w alert.Webhook) string {
+	alerts := make([]string, 0, len(w.Alerts))
+	for _, a := range w.Alerts {
+		alerts = append(alerts, a.Fingerprint+"="+a.Status)
+	}
+	sort.Strings(alerts)
+	sum := sha256.Sum256([]byte(strings.Join(alerts, ",")))
+	return hex.EncodeToString(sum[:])
+}
+
+// endpointLabel returns the name identifying the endpoint in logs and
+// metrics.
+func endpointLabel(endpoint string) string {
+	if endpoint == "" {
+		return conf.DefaultEndpoint
+	}
+	return endpoint
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "alertfy"
+
+// registry holds all alertfy metrics along with the Go runtime and process
+// metrics.
+var registry = prometheus.NewRegistry()
+
+var factory = promauto.With(registry)
+
+var (
+	// WebhookRequests counts the requests to the webhook endpoint by HTTP
+	// status code.
+	WebhookRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_requests_total",
+		Help:      "Requests to the webhook endpoint by HTTP status code.",
+	}, []string{"code"})
+
+	// AlertsReceived counts the alerts received by webhook endpoint and
+	// status.
+	AlertsReceived = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alerts_received_total",
+		Help:      "Alerts received from Alertmanager by webhook endpoint and status.",
+	}, []string{"endpoint", "status"})
+
+	// NotificationsSent counts the notifications accepted by the ntfy server
+	// by topic and priority.
+	NotificationsSent = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_sent_total",
+		Help:      "Notifications accepted by the ntfy server by topic and priority.",
+	}, []string{"topic", "priority"})
+
+	// NotificationsFailed counts the notifications that failed permanently by
+	// topic and priority.
+	NotificationsFailed = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_failed_total",
+		Help:      "Notifications that failed permanently by topic and priority.",
+	}, []string{"topic", "priority"})
+
+	// NotificationsSuppressed counts the notifications that were not sent on
+	// purpose by reason, which is either "duplicate" or "rate_limit".
+	NotificationsSuppressed = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_suppressed_total",
+		Help:      "Notifications not sent on purpose by reason.",
+	}, []string{"reason"})
+
+	// NotifierRequestDuration observes the latency of requests to the
+	// notification services by notifier and HTTP status code. The code is
+	// "error" if no response was received.
+	NotifierRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "notifier_request_duration_seconds",
+		Help:      "Latency of requests to the notification services by notifier and HTTP status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"notifier", "code"})
+
+	// RenderErrors counts the failed evaluations of templates and expressions
+	// by notification field.
+	RenderErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "render_errors_total",
+		Help:      "Failed evaluations of templates and expressions by notification field.",
+	}, []string{"field"})
+
+	// QueueDepth is the number of notifications waiting in the delivery
+	// queue.
+	QueueDepth = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_depth",
+		Help:      "Notifications waiting in the delivery queue.",
+	})
+
+	// DeadLetters is the number of entries in the dead-letter store.
+	DeadLetters = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "dead_letters",
+		Help:      "Entries in the dead-letter store.",
+	})
+
+	// NtfyServerUp reports whether the circuit breaker of a ntfy server lets
+	// the notifications of an endpoint through, by endpoint and server base
+	// URL. Every endpoint has its own circuit breakers.
+	NtfyServerUp = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ntfy_server_up",
+		Help:      "Whether a ntfy server is considered available by an endpoint, by endpoint and base URL.",
+	}, []string{"endpoint", "server"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler returns an HTTP handler serving the metrics in the Prometheus
+// exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
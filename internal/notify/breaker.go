@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"sync"
+	"time"
+)
+
+// breaker is a circuit breaker tracking the health of a server from the
+// outcome of the requests sent to it. It opens once enough consecutive
+// requests failed, and lets a single request through to probe the server
+// once it has been open for long enough. A breaker without a failure
+// threshold never opens.
+type breaker struct {
+	failures int
+	openFor  time.Duration
+	now      func() time.Time
+
+	// mu guards the state below. consecutive is the number of consecutive
+	// failed requests, and the breaker is open while it is at least failures.
+	// probing reports whether a probe is in flight.
+	mu          sync.Mutex
+	consecutive int
+	openedAt    time.Time
+	probing     bool
+}
+
+func newBreaker(failures int, openFor time.Duration) *breaker {
+	return &breaker{failures: failures, openFor: openFor, now: time.Now}
+}
+
+// allow reports whether a request may be sent to the server. Every request
+// allowed must be followed by a call to record or release.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open() {
+		return true
+	}
+	if b.probing || b.now().Sub(b.openedAt) < b.openFor {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record records the outcome of a request. It reports whether the breaker
+// opened or closed as a result.
+func (b *breaker) record(ok bool) (opened, closed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	wasOpen := b.open()
+	if ok {
+		b.consecutive = 0
+		return false, wasOpen
+	}
+	b.consecutive++
+	if b.open() {
+		// restart the wait after a failed probe
+		b.openedAt = b.now()
+	}
+	return !wasOpen && b.open(), false
+}
+
+// release lets a request allowed by allow go without recording its outcome,
+// for requests whose outcome says nothing about the health of the server.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// isOpen reports whether the breaker is open.
+func (b *breaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open()
+}
+
+func (b *breaker) open() bool {
+	return b.failures > 0 && b.consecutive >= b.failures
+}
@@ -0,0 +1,247 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/ntfy"
+	"github.com/murtaza-u/alertfy/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// emailTimeout bounds a whole SMTP session if the context has no deadline.
+const emailTimeout = time.Minute
+
+// emailPriority maps ntfy priority levels to the X-Priority header, where 1
+// is the highest priority.
+var emailPriority = [...]string{1: "5", 2: "4", 3: "3", 4: "2", 5: "1"}
+
+// email sends notifications by email through an SMTP relay.
+type email struct {
+	conf conf.Email
+	// tlsConfig is used for STARTTLS. If nil, the server name is set to the
+	// host of the relay.
+	tlsConfig *tls.Config
+}
+
+// NewEmail creates a notifier sending notifications by email with the
+// provided configuration. It is used as the fallback of other notifiers.
+func NewEmail(c conf.Email) Notifier {
+	return email{conf: c}
+}
+
+func (e email) Notify(ctx context.Context, d ntfy.Data) (string, *Error) {
+	ctx, span := tracer.Start(ctx, "email.send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("server.address", e.conf.Address)),
+	)
+	defer span.End()
+
+	msg, err := e.message(d)
+	if err != nil {
+		err := &Error{Err: fmt.Errorf("creating email: %w", err)}
+		tracing.Fail(span, err)
+		return "", err
+	}
+	if err := e.send(ctx, msg); err != nil {
+		err := &Error{
+			Err:       fmt.Errorf("sending email through %s: %w", e.conf.Address, err),
+			Retryable: retryableSMTP(err),
+		}
+		tracing.Fail(span, err)
+		return "", err
+	}
+	return e.conf.Address, nil
+}
+
+// send delivers the message to every recipient in a single SMTP session.
+func (e email) send(ctx context.Context, msg []byte) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, emailTimeout)
+		defer cancel()
+	}
+	host, _, err := net.SplitHostPort(e.conf.Address)
+	if err != nil {
+		return err
+	}
+
+	conn, err := new(net.Dialer).DialContext(ctx, "tcp", e.conf.Address)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if !e.conf.DisableStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("server does not support STARTTLS")
+		}
+		cfg := e.tlsConfig
+		if cfg == nil {
+			cfg = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+		}
+		if err := c.StartTLS(cfg); err != nil {
+			return err
+		}
+	}
+	if e.conf.Auth.Enable {
+		auth := smtp.PlainAuth("", e.conf.Auth.Username, e.conf.Auth.Password, host)
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	from, err := mail.ParseAddress(e.conf.From)
+	if err != nil {
+		return err
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, to := range e.conf.To {
+		addr, err := mail.ParseAddress(to)
+		if err != nil {
+			return err
+		}
+		if err := c.Rcpt(addr.Address); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message creates the email for the notification. The subject is the title,
+// or the first line of the description if the title is empty. The body is the
+// description, along with the HTML body if one was rendered.
+func (e email) message(d ntfy.Data) ([]byte, error) {
+	subject := strings.TrimSpace(d.Title)
+	if subject == "" {
+		subject, _, _ = strings.Cut(strings.TrimSpace(d.Description), "\n")
+	}
+
+	header := [][2]string{
+		{"From", e.conf.From},
+		{"To", strings.Join(e.conf.To, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", messageID(e.conf.From)},
+		{"X-Priority", emailPriority[ntfy.PriorityLevel(d.Priority)]},
+		{"MIME-Version", "1.0"},
+	}
+	buf := new(bytes.Buffer)
+	if d.HTML == "" {
+		header = append(header,
+			[2]string{"Content-Type", "text/plain; charset=utf-8"},
+			[2]string{"Content-Transfer-Encoding", "quoted-printable"},
+		)
+		writeHeader(buf, header)
+		if err := writeQuotedPrintable(buf, message(d)); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+	parts := []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", message(d)},
+		{"text/html; charset=utf-8", d.HTML},
+	}
+	for _, p := range parts {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, p.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	header = append(header, [2]string{
+		"Content-Type", "multipart/alternative; boundary=" + mw.Boundary(),
+	})
+	writeHeader(buf, header)
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+func writeHeader(w io.Writer, header [][2]string) {
+	for _, h := range header {
+		fmt.Fprintf(w, "%s: %s\r\n", h[0], h[1])
+	}
+	io.WriteString(w, "\r\n")
+}
+
+func writeQuotedPrintable(w io.Writer, s string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qp, s); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// messageID returns a unique Message-Id in the domain of the sender.
+func messageID(from string) string {
+	domain := "alertfy"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if _, d, ok := strings.Cut(addr.Address, "@"); ok {
+			domain = d
+		}
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "." +
+		strconv.FormatInt(time.Now().UnixNano(), 36) + "@" + domain + ">"
+}
+
+// retryableSMTP reports whether a failed SMTP session may succeed if retried.
+// Replies with a 4XX code are transient, 5XX codes are permanent, and
+// connection failures are retried.
+func retryableSMTP(err error) bool {
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) {
+		return tpErr.Code >= 400 && tpErr.Code < 500
+	}
+	return true
+}
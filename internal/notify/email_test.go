@@ -0,0 +1,222 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/ntfy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// smtpServer is a minimal SMTP server standing in for a relay. Recipients
+// starting with "busy" are rejected with a transient error, and recipients
+// starting with "unknown" with a permanent one.
+type smtpServer struct {
+	addr string
+	// tlsConfig is nil if STARTTLS is not supported.
+	tlsConfig *tls.Config
+
+	mu       sync.Mutex
+	auth     string
+	tls      bool
+	rcpts    []string
+	messages [][]byte
+}
+
+func startSMTP(t *testing.T, tlsConfig *tls.Config) *smtpServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	s := &smtpServer{addr: l.Addr().String(), tlsConfig: tlsConfig}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(cmd) {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250-localhost")
+			if s.tlsConfig != nil {
+				tp.PrintfLine("250-STARTTLS")
+			}
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "STARTTLS":
+			tp.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn = tlsConn
+			tp = textproto.NewConn(conn)
+			s.mu.Lock()
+			s.tls = true
+			s.mu.Unlock()
+		case "AUTH":
+			_, creds, _ := strings.Cut(arg, " ")
+			b, _ := base64.StdEncoding.DecodeString(creds)
+			s.mu.Lock()
+			s.auth = string(b)
+			s.mu.Unlock()
+			tp.PrintfLine("235 authenticated")
+		case "RCPT":
+			rcpt := strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+			switch {
+			case strings.HasPrefix(rcpt, "busy"):
+				tp.PrintfLine("451 try again later")
+			case strings.HasPrefix(rcpt, "unknown"):
+				tp.PrintfLine("550 no such user")
+			default:
+				s.mu.Lock()
+				s.rcpts = append(s.rcpts, rcpt)
+				s.mu.Unlock()
+				tp.PrintfLine("250 ok")
+			}
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			msg, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("250 ok")
+		}
+	}
+}
+
+func TestEmail(t *testing.T) {
+	a := assert.New(t)
+	// borrow the certificate of a TLS test server, which is valid for
+	// 127.0.0.1
+	srv := httptest.NewTLSServer(nil)
+	defer srv.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	relay := startSMTP(t, &tls.Config{Certificates: srv.TLS.Certificates})
+
+	e := email{
+		conf: conf.Email{
+			Address: relay.addr,
+			Auth:    conf.Auth{Enable: true, Username: "alertfy", Password: "secret"},
+			From:    "Alertfy <alertfy@example.com>",
+			To:      []string{"oncall@example.com", "Ops <ops@example.com>"},
+		},
+		tlsConfig: &tls.Config{RootCAs: roots, ServerName: "127.0.0.1"},
+	}
+	server, nerr := e.Notify(context.Background(), ntfy.Data{
+		Title:       "Disk full ✗",
+		Description: "node a",
+		Priority:    "urgent",
+		Tags:        "warning",
+		HTML:        "<p>node a</p>",
+	})
+	a.Nil(nerr)
+	a.Equal(relay.addr, server)
+
+	relay.mu.Lock()
+	defer relay.mu.Unlock()
+	a.True(relay.tls)
+	a.Equal("\x00alertfy\x00secret", relay.auth)
+	a.Equal([]string{"oncall@example.com", "ops@example.com"}, relay.rcpts)
+	require.Len(t, relay.messages, 1)
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(relay.messages[0])))
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	a.NoError(err)
+	a.Equal("Disk full ✗", subject)
+	a.Equal("1", msg.Header.Get("X-Priority"))
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	a.Equal("multipart/alternative", mediaType)
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var bodies []string
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		b, err := io.ReadAll(p)
+		require.NoError(t, err)
+		bodies = append(bodies, string(b))
+	}
+	a.Equal([]string{"node a\n\nTags: warning", "<p>node a</p>"}, bodies)
+}
+
+func TestEmailErrors(t *testing.T) {
+	a := assert.New(t)
+	relay := startSMTP(t, nil)
+	c := conf.Email{
+		Address: relay.addr,
+		From:    "alertfy@example.com",
+		To:      []string{"oncall@example.com"},
+	}
+
+	_, err := email{conf: c}.Notify(context.Background(), ntfy.Data{Description: "x"})
+	if a.NotNil(err, "STARTTLS is required") {
+		a.Contains(err.Error(), "STARTTLS")
+	}
+
+	c.DisableStartTLS = true
+	_, err = email{conf: c}.Notify(context.Background(), ntfy.Data{Description: "x"})
+	a.Nil(err)
+	relay.mu.Lock()
+	msg := string(relay.messages[0])
+	relay.mu.Unlock()
+	header, _ := textproto.NewReader(bufio.NewReader(strings.NewReader(msg))).
+		ReadMIMEHeader()
+	a.Equal("x", header.Get("Subject"), "description is the subject without title")
+	a.Equal("text/plain; charset=utf-8", header.Get("Content-Type"))
+
+	c.To = []string{"busy@example.com"}
+	_, err = email{conf: c}.Notify(context.Background(), ntfy.Data{Description: "x"})
+	if a.NotNil(err) {
+		a.True(err.Retryable)
+	}
+	c.To = []string{"unknown@example.com"}
+	_, err = email{conf: c}.Notify(context.Background(), ntfy.Data{Description: "x"})
+	if a.NotNil(err) {
+		a.False(err.Retryable)
+	}
+}
@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/ntfy"
+)
+
+// gotifyPriority maps ntfy priority levels to Gotify priorities. Gotify
+// clients treat 1-3 as low, 4-7 as normal and 8-10 as high priority.
+var gotifyPriority = [...]int{1: 1, 2: 3, 3: 5, 4: 8, 5: 10}
+
+// gotify publishes notifications to a Gotify server.
+//
+// Reference: https://gotify.net/api-docs#/message/createMessage
+type gotify struct {
+	conf   conf.Ntfy
+	client *http.Client
+}
+
+type gotifyMessage struct {
+	Title    string `json:"title,omitempty"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+}
+
+func (g gotify) Notify(ctx context.Context, d ntfy.Data) (string, *Error) {
+	return g.conf.BaseURL, send(ctx, g.client, conf.NotifierGotify,
+		func(ctx context.Context) (*http.Request, error) {
+			body, err := json.Marshal(gotifyMessage{
+				Title:    d.Title,
+				Message:  message(d),
+				Priority: gotifyPriority[ntfy.PriorityLevel(d.Priority)],
+			})
+			if err != nil {
+				return nil, err
+			}
+			u, err := url.JoinPath(g.conf.BaseURL, "message")
+			if err != nil {
+				return nil, err
+			}
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, u,
+				bytes.NewReader(body))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Gotify-Key", g.conf.Gotify.Token)
+			if g.conf.Auth.Enable {
+				req.SetBasicAuth(g.conf.Auth.Username, g.conf.Auth.Password)
+			}
+			return req, nil
+		})
+}
@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/murtaza-u/alertfy/internal/metrics"
+	"github.com/murtaza-u/alertfy/internal/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("notify")
+
+// send sends the request created by newRequest to the service named by
+// notifier. The request is traced, and the trace context is propagated to the
+// service. Responses with a 429 or 5XX status code are retryable.
+func send(
+	ctx context.Context,
+	client *http.Client,
+	notifier string,
+	newRequest func(context.Context) (*http.Request, error),
+) *Error {
+	ctx, span := tracer.Start(ctx, notifier+".publish",
+		trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	err := do(ctx, span, client, notifier, newRequest)
+	if err != nil {
+		tracing.Fail(span, err)
+	}
+	return err
+}
+
+func do(
+	ctx context.Context,
+	span trace.Span,
+	client *http.Client,
+	notifier string,
+	newRequest func(context.Context) (*http.Request, error),
+) *Error {
+	req, err := newRequest(ctx)
+	if err != nil {
+		return &Error{Err: fmt.Errorf("creating http request: %w", err)}
+	}
+	span.SetAttributes(
+		attribute.String("http.request.method", req.Method),
+		attribute.String("url.full", req.URL.Redacted()),
+	)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		metrics.NotifierRequestDuration.WithLabelValues(notifier, "error").
+			Observe(time.Since(start).Seconds())
+		return &Error{
+			Err:       fmt.Errorf("forwarding request to %s: %w", notifier, err),
+			Retryable: ctx.Err() == nil,
+		}
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	metrics.NotifierRequestDuration.
+		WithLabelValues(notifier, strconv.Itoa(resp.StatusCode)).
+		Observe(time.Since(start).Seconds())
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	return &Error{
+		Err: fmt.Errorf("non-2XX status code received from %s: %s",
+			notifier, resp.Status),
+		Retryable: resp.StatusCode == http.StatusTooManyRequests ||
+			resp.StatusCode >= 500,
+		RetryAfter:  retryAfter(resp.Header.Get("Retry-After")),
+		RateLimited: resp.StatusCode == http.StatusTooManyRequests,
+	}
+}
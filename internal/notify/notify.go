@@ -0,0 +1,88 @@
+// Package notify publishes rendered notifications to the supported
+// notification services.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/ntfy"
+)
+
+// Notifier publishes notifications to a notification service.
+type Notifier interface {
+	// Notify makes a single attempt at publishing the notification. If the
+	// notification is accepted, it returns the server that accepted it, such
+	// as the base URL of the ntfy server. Failed attempts are reported as an
+	// *Error.
+	Notify(ctx context.Context, n ntfy.Data) (string, *Error)
+}
+
+// Error is returned by a failed attempt at publishing a notification.
+type Error struct {
+	Err error
+	// Retryable reports whether the attempt may succeed if retried.
+	Retryable bool
+	// RetryAfter is the delay requested by the service through the
+	// Retry-After header, if any.
+	RetryAfter time.Duration
+	// RateLimited reports whether the service rejected the attempt with a
+	// 429 status code.
+	RateLimited bool
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New creates the notifier configured by c for the named endpoint, which
+// identifies it in metrics. Requests are sent using the provided client.
+func New(endpoint string, c conf.Ntfy, client *http.Client) (Notifier, error) {
+	switch c.Notifier {
+	case conf.NotifierNtfy:
+		return newNtfy(endpoint, c, client), nil
+	case conf.NotifierGotify:
+		return gotify{conf: c, client: client}, nil
+	case conf.NotifierPushover:
+		return pushover{conf: c, client: client}, nil
+	case conf.NotifierWebhook:
+		return webhook{auth: c.Auth, client: client}, nil
+	case conf.NotifierEmail:
+		return NewEmail(c.Email), nil
+	}
+	return nil, fmt.Errorf("unknown notifier %q", c.Notifier)
+}
+
+// message returns the body of a notification for services without tags,
+// with the tags appended to the description.
+func message(n ntfy.Data) string {
+	if n.Tags == "" {
+		return n.Description
+	}
+	return n.Description + "\n\nTags: " + strings.ReplaceAll(n.Tags, ",", ", ")
+}
+
+// retryAfter parses the value of a Retry-After header, which is either a
+// number of seconds or an HTTP date. It returns 0 if the value is empty or
+// invalid.
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return max(time.Duration(secs)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
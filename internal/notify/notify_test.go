@@ -0,0 +1,336 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/murtaza-u/alertfy/internal/alert"
+	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/metrics"
+	"github.com/murtaza-u/alertfy/internal/ntfy"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGotify(t *testing.T) {
+	a := assert.New(t)
+	var got gotifyMessage
+	var req *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			req = r
+			json.NewDecoder(r.Body).Decode(&got)
+		},
+	))
+	defer srv.Close()
+
+	n, err := New(conf.DefaultEndpoint, conf.Ntfy{
+		Notifier: conf.NotifierGotify,
+		BaseURL:  srv.URL + "/gotify",
+		Gotify:   conf.Gotify{Token: "app-token"},
+	}, srv.Client())
+	require.NoError(t, err)
+
+	server, nerr := n.Notify(context.Background(), ntfy.Data{
+		Title:       "disk full",
+		Description: "node a",
+		Priority:    "high",
+		Tags:        "warning,disk",
+	})
+	a.Nil(nerr)
+	a.Equal(srv.URL+"/gotify", server)
+	a.Equal("/gotify/message", req.URL.Path)
+	a.Equal("app-token", req.Header.Get("X-Gotify-Key"))
+	a.Equal(gotifyMessage{
+		Title:    "disk full",
+		Message:  "node a\n\nTags: warning, disk",
+		Priority: 8,
+	}, got)
+
+	for priority, want := range map[string]int{
+		"min": 1, "low": 3, "": 5, "default": 5, "high": 8, "urgent": 10, "5": 10,
+	} {
+		_, nerr := n.Notify(context.Background(), ntfy.Data{Priority: priority})
+		a.Nil(nerr)
+		a.Equalf(want, got.Priority, "priority=%s", priority)
+	}
+}
+
+func TestPushover(t *testing.T) {
+	a := assert.New(t)
+	var form url.Values
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			r.ParseForm()
+			form, path = r.PostForm, r.URL.Path
+			if form.Get("token") != "app-token" {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		},
+	))
+	defer srv.Close()
+
+	c := conf.Ntfy{
+		Notifier: conf.NotifierPushover,
+		BaseURL:  srv.URL,
+		Pushover: conf.Pushover{
+			Token:  "app-token",
+			User:   "user-key",
+			Retry:  time.Minute,
+			Expire: time.Hour,
+		},
+	}
+	n, err := New(conf.DefaultEndpoint, c, srv.Client())
+	require.NoError(t, err)
+
+	_, nerr := n.Notify(context.Background(), ntfy.Data{
+		Description: "node a",
+		Priority:    "low",
+	})
+	a.Nil(nerr)
+	a.Equal("/1/messages.json", path)
+	a.Equal("user-key", form.Get("user"))
+	a.Equal("node a", form.Get("message"))
+	a.Equal("-1", form.Get("priority"))
+	a.False(form.Has("title"))
+	a.False(form.Has("retry"))
+
+	_, nerr = n.Notify(context.Background(), ntfy.Data{
+		Title:       "disk full",
+		Description: "node a",
+		Priority:    "urgent",
+	})
+	a.Nil(nerr)
+	a.Equal("disk full", form.Get("title"))
+	a.Equal("2", form.Get("priority"))
+	a.Equal("60", form.Get("retry"))
+	a.Equal("3600", form.Get("expire"))
+
+	c.Pushover.Token = "wrong"
+	n, err = New(conf.DefaultEndpoint, c, srv.Client())
+	require.NoError(t, err)
+	_, nerr = n.Notify(context.Background(), ntfy.Data{Description: "node a"})
+	if a.NotNil(nerr) {
+		a.False(nerr.Retryable)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	a := assert.New(t)
+	var req *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			req = r
+			body, _ = io.ReadAll(r.Body)
+			if r.Header.Get("X-Team") == "" {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		},
+	))
+	defer srv.Close()
+
+	tmpl := func(text string) *conf.Template {
+		tmpl := new(conf.Template)
+		require.NoError(t, tmpl.UnmarshalText([]byte(text)))
+		return tmpl
+	}
+	c := conf.Ntfy{
+		Notifier: conf.NotifierWebhook,
+		Auth:     conf.Auth{Enable: true, Username: "bot", Password: "secret"},
+		Webhook: conf.Webhook{
+			URL:    srv.URL + "/alerts",
+			Method: tmpl(`{{ if eq .Status "firing" }}put{{ else }}DELETE{{ end }}`),
+			Headers: map[string]*conf.Template{
+				"X-Team": tmpl(`{{ index .Labels "team" }}`),
+			},
+			Body: tmpl(`{"summary": {{ printf "%q" (index .Annotations "summary") }}}`),
+		},
+		Notification: conf.Notification{
+			Title:       tmpl(""),
+			Description: tmpl(""),
+		},
+	}
+	a1 := alert.Alert{
+		Status:      "firing",
+		Labels:      map[string]string{"team": "db"},
+		Annotations: map[string]string{"summary": `disk "full"`},
+	}
+	notifications, err := ntfy.NewParser(c).Parse(context.Background(),
+		alert.NewData(alert.Webhook{Alerts: alert.Alerts{a1}}, a1))
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	d := notifications[0]
+
+	n, err := New(conf.DefaultEndpoint, c, srv.Client())
+	require.NoError(t, err)
+	server, nerr := n.Notify(context.Background(), d)
+	a.Nil(nerr)
+	a.Equal(srv.URL+"/alerts", server)
+	a.Equal(http.MethodPut, req.Method)
+	a.Equal("/alerts", req.URL.Path)
+	a.Equal("db", req.Header.Get("X-Team"))
+	a.Equal("application/json", req.Header.Get("Content-Type"))
+	username, password, _ := req.BasicAuth()
+	a.Equal("bot", username)
+	a.Equal("secret", password)
+	a.JSONEq(`{"summary": "disk \"full\""}`, string(body))
+
+	d.Webhook.Headers = nil
+	_, nerr = n.Notify(context.Background(), d)
+	if a.NotNil(nerr) {
+		a.True(nerr.Retryable)
+	}
+	d.Webhook = nil
+	_, nerr = n.Notify(context.Background(), d)
+	if a.NotNil(nerr) {
+		a.False(nerr.Retryable)
+	}
+}
+
+func TestNtfyFailover(t *testing.T) {
+	a := assert.New(t)
+	primaryStatus := http.StatusBadGateway
+	var primaryCalls, secondaryCalls int
+	var auth string
+	primary := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			primaryCalls++
+			w.WriteHeader(primaryStatus)
+		},
+	))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			secondaryCalls++
+			auth, _, _ = r.BasicAuth()
+			a.Equal("/alerts", r.URL.Path)
+		},
+	))
+	defer secondary.Close()
+
+	n := newNtfy(conf.DefaultEndpoint, conf.Ntfy{
+		Servers: []conf.Server{
+			{BaseURL: primary.URL},
+			{
+				BaseURL: secondary.URL,
+				Auth:    conf.Auth{Enable: true, Username: "bot", Password: "secret"},
+			},
+		},
+		CircuitBreaker: conf.CircuitBreaker{Failures: 2, OpenFor: time.Minute},
+	}, http.DefaultClient)
+	now := time.Now()
+	for _, s := range n.servers {
+		s.breaker.now = func() time.Time { return now }
+	}
+	notify := func() string {
+		server, err := n.Notify(context.Background(), ntfy.Data{Topic: "alerts"})
+		a.Nil(err)
+		return server
+	}
+
+	a.Equal(secondary.URL, notify())
+	a.Equal("bot", auth)
+	a.Equal(secondary.URL, notify())
+	a.Equal(2, primaryCalls)
+	a.Equal(secondary.URL, notify(), "primary is skipped once the breaker opens")
+	a.Equal(2, primaryCalls)
+
+	now = now.Add(time.Minute)
+	a.Equal(secondary.URL, notify(), "failed probe keeps the breaker open")
+	a.Equal(3, primaryCalls)
+	a.Equal(secondary.URL, notify())
+	a.Equal(3, primaryCalls)
+
+	now = now.Add(time.Minute)
+	primaryStatus = http.StatusOK
+	a.Equal(primary.URL, notify(), "primary recovers after a successful probe")
+	a.Equal(primary.URL, notify())
+	a.Equal(5, secondaryCalls)
+
+	primaryStatus = http.StatusBadRequest
+	_, err := n.Notify(context.Background(), ntfy.Data{Topic: "alerts"})
+	if a.NotNil(err, "rejected notifications are not failed over") {
+		a.False(err.Retryable)
+	}
+	a.Equal(5, secondaryCalls)
+
+	primary.Close()
+	secondary.Close()
+	for range 2 {
+		_, err = n.Notify(context.Background(), ntfy.Data{Topic: "alerts"})
+		a.NotNil(err)
+	}
+	_, err = n.Notify(context.Background(), ntfy.Data{Topic: "alerts"})
+	if a.NotNil(err, "every breaker is open") {
+		a.True(err.Retryable)
+		a.Contains(err.Error(), "no ntfy server is available")
+	}
+}
+
+func TestNtfyRateLimited(t *testing.T) {
+	a := assert.New(t)
+	var secondaryCalls int
+	primary := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+		},
+	))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			secondaryCalls++
+		},
+	))
+	defer secondary.Close()
+
+	c := conf.Ntfy{
+		Servers:        []conf.Server{{BaseURL: primary.URL}, {BaseURL: secondary.URL}},
+		CircuitBreaker: conf.CircuitBreaker{Failures: 1, OpenFor: time.Minute},
+	}
+	n := newNtfy("team", c, http.DefaultClient)
+	other := newNtfy("ops", c, http.DefaultClient)
+	up := func(endpoint string) float64 {
+		return testutil.ToFloat64(metrics.NtfyServerUp.WithLabelValues(endpoint, primary.URL))
+	}
+
+	for range 2 {
+		_, err := n.Notify(context.Background(), ntfy.Data{Topic: "alerts"})
+		if a.NotNil(err) {
+			a.True(err.RateLimited)
+			a.True(err.Retryable)
+			a.Equal(30*time.Second, err.RetryAfter)
+		}
+	}
+	a.Zero(secondaryCalls, "rate limited notifications are not failed over")
+	a.False(n.servers[0].breaker.isOpen(), "rate limiting does not open the breaker")
+	a.Equal(1.0, up("team"))
+
+	// the breaker of an endpoint opening does not affect the other endpoints
+	n.servers[0].record(context.Background(), false)
+	a.Equal(0.0, up("team"))
+	a.Equal(1.0, up("ops"))
+	a.False(other.servers[0].breaker.isOpen())
+}
+
+func TestRetryAfter(t *testing.T) {
+	a := assert.New(t)
+	a.Equal(time.Duration(0), retryAfter(""))
+	a.Equal(time.Duration(0), retryAfter("soon"))
+	a.Equal(time.Duration(0), retryAfter("-5"))
+	a.Equal(30*time.Second, retryAfter("30"))
+
+	d := retryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	a.Greater(d, 50*time.Second)
+	a.LessOrEqual(d, time.Minute)
+}
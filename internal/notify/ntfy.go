@@ -0,0 +1,142 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/metrics"
+	"github.com/murtaza-u/alertfy/internal/ntfy"
+)
+
+// ntfyServer is a ntfy server along with the circuit breaker tracking its
+// health for the endpoint.
+type ntfyServer struct {
+	conf     conf.Server
+	endpoint string
+	breaker  *breaker
+}
+
+// ntfyNotifier publishes notifications to the first healthy ntfy server,
+// failing over to the next one if publishing fails.
+type ntfyNotifier struct {
+	servers []*ntfyServer
+	client  *http.Client
+}
+
+func newNtfy(endpoint string, c conf.Ntfy, client *http.Client) ntfyNotifier {
+	servers := c.Servers
+	if len(servers) == 0 {
+		servers = []conf.Server{{BaseURL: c.BaseURL, Auth: c.Auth}}
+	}
+	n := ntfyNotifier{client: client}
+	if c.Timeout > 0 {
+		withTimeout := *client
+		withTimeout.Timeout = c.Timeout
+		n.client = &withTimeout
+	}
+	for _, s := range servers {
+		server := &ntfyServer{
+			conf:     s,
+			endpoint: endpoint,
+			breaker:  newBreaker(c.CircuitBreaker.Failures, c.CircuitBreaker.OpenFor),
+		}
+		server.report()
+		n.servers = append(n.servers, server)
+	}
+	return n
+}
+
+func (n ntfyNotifier) Notify(ctx context.Context, d ntfy.Data) (string, *Error) {
+	var last *Error
+	for _, s := range n.servers {
+		if !s.breaker.allow() {
+			continue
+		}
+		err := n.publish(ctx, s.conf, d)
+		if err != nil && err.RateLimited {
+			// rate limiting is not an outage: the server is neither counted
+			// as failing nor failed over from, and the notification is
+			// retried after the delay it requested
+			s.breaker.release()
+			return "", err
+		}
+		// rejected notifications say nothing about the health of the server
+		s.record(ctx, err == nil || !err.Retryable)
+		if err == nil {
+			return s.conf.BaseURL, nil
+		}
+		if !err.Retryable || ctx.Err() != nil {
+			return "", err
+		}
+		last = err
+		slog.LogAttrs(
+			ctx,
+			slog.LevelWarn,
+			"failed to publish notification to ntfy server. Trying the next one",
+			slog.String("server", s.conf.BaseURL),
+			slog.String("error", err.Error()),
+		)
+	}
+	if last == nil {
+		return "", &Error{
+			Err:       errors.New("no ntfy server is available"),
+			Retryable: true,
+		}
+	}
+	return "", last
+}
+
+// publish sends the notification to the topic on the provided server.
+func (n ntfyNotifier) publish(ctx context.Context, s conf.Server, d ntfy.Data) *Error {
+	u, err := url.JoinPath(s.BaseURL, d.Topic)
+	if err != nil {
+		return &Error{Err: fmt.Errorf("appending %q to %q: %w", d.Topic, s.BaseURL, err)}
+	}
+	d.URL = u
+	return send(ctx, n.client, conf.NotifierNtfy,
+		func(ctx context.Context) (*http.Request, error) {
+			return ntfy.NewRequest(ctx, ntfy.RequestData{
+				Notification: d,
+				BasicAuth:    s.Auth,
+			})
+		})
+}
+
+// record records the outcome of a request in the circuit breaker of the
+// server, logging the server becoming unavailable or recovering.
+func (s *ntfyServer) record(ctx context.Context, ok bool) {
+	opened, closed := s.breaker.record(ok)
+	s.report()
+	switch {
+	case opened:
+		slog.LogAttrs(
+			ctx,
+			slog.LevelWarn,
+			"ntfy server keeps failing. Skipping it",
+			slog.String("server", s.conf.BaseURL),
+			slog.Duration("openFor", s.breaker.openFor),
+		)
+	case closed:
+		slog.LogAttrs(
+			ctx,
+			slog.LevelInfo,
+			"ntfy server recovered",
+			slog.String("server", s.conf.BaseURL),
+		)
+	}
+}
+
+// report sets the availability of the server reported in metrics from the
+// state of its circuit breaker.
+func (s *ntfyServer) report() {
+	up := 1.0
+	if s.breaker.isOpen() {
+		up = 0
+	}
+	metrics.NtfyServerUp.WithLabelValues(s.endpoint, s.conf.BaseURL).Set(up)
+}
@@ -0,0 +1,64 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/ntfy"
+)
+
+// pushoverBaseURL is the base URL of the Pushover API, used if no base URL is
+// configured.
+const pushoverBaseURL = "https://api.pushover.net"
+
+// pushoverPriority maps ntfy priority levels to Pushover priorities, where 2
+// is an emergency notification that is repeated until acknowledged.
+var pushoverPriority = [...]int{1: -2, 2: -1, 3: 0, 4: 1, 5: 2}
+
+// pushover publishes notifications through the Pushover API.
+//
+// Reference: https://pushover.net/api
+type pushover struct {
+	conf   conf.Ntfy
+	client *http.Client
+}
+
+func (p pushover) Notify(ctx context.Context, d ntfy.Data) (string, *Error) {
+	base := p.conf.BaseURL
+	if base == "" {
+		base = pushoverBaseURL
+	}
+	return base, send(ctx, p.client, conf.NotifierPushover,
+		func(ctx context.Context) (*http.Request, error) {
+			priority := pushoverPriority[ntfy.PriorityLevel(d.Priority)]
+			form := url.Values{
+				"token":    {p.conf.Pushover.Token},
+				"user":     {p.conf.Pushover.User},
+				"message":  {message(d)},
+				"priority": {strconv.Itoa(priority)},
+			}
+			if d.Title != "" {
+				form.Set("title", d.Title)
+			}
+			if priority == 2 {
+				form.Set("retry", strconv.Itoa(int(p.conf.Pushover.Retry.Seconds())))
+				form.Set("expire", strconv.Itoa(int(p.conf.Pushover.Expire.Seconds())))
+			}
+
+			u, err := url.JoinPath(base, "1", "messages.json")
+			if err != nil {
+				return nil, err
+			}
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, u,
+				strings.NewReader(form.Encode()))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return req, nil
+		})
+}
@@ -0,0 +1,40 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/ntfy"
+)
+
+// webhook sends the request rendered for the notification to an arbitrary
+// HTTP endpoint.
+type webhook struct {
+	auth   conf.Auth
+	client *http.Client
+}
+
+func (w webhook) Notify(ctx context.Context, d ntfy.Data) (string, *Error) {
+	if d.Webhook == nil {
+		return "", &Error{Err: errors.New("no webhook request was rendered for the notification")}
+	}
+	return d.URL, send(ctx, w.client, conf.NotifierWebhook,
+		func(ctx context.Context) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, d.Webhook.Method, d.URL,
+				strings.NewReader(d.Webhook.Body))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			for name, value := range d.Webhook.Headers {
+				req.Header.Set(name, value)
+			}
+			if w.auth.Enable {
+				req.SetBasicAuth(w.auth.Username, w.auth.Password)
+			}
+			return req, nil
+		})
+}
@@ -1,13 +1,47 @@
 package ntfy
 
+import "strings"
+
 // Data contains all the details of the notification.
 type Data struct {
-	URL         string
-	Title       string
-	Description string
-	Priority    string
-	Tags        string
+	URL         string `json:"url"`
+	Topic       string `json:"topic"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Priority    string `json:"priority"`
+	Tags        string `json:"tags"`
+	// Webhook is the request rendered for the webhook notifier. Nil for the
+	// other notifiers.
+	Webhook *Webhook `json:"webhook,omitempty"`
+	// HTML is the HTML body rendered for emails. Empty if no HTML template is
+	// configured or email is not used.
+	HTML string `json:"html,omitempty"`
+}
+
+// Webhook is an HTTP request rendered for the webhook notifier.
+type Webhook struct {
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body"`
 }
 
 // defaultPriority is the priority level used when none is specified.
 const defaultPriority = "default"
+
+// PriorityLevel returns the numeric level, from 1 (min) to 5 (max), of the
+// provided ntfy priority. Unknown priorities map to the default level, 3.
+//
+// Reference: https://docs.ntfy.sh/publish/#message-priority
+func PriorityLevel(priority string) int {
+	switch strings.ToLower(strings.TrimSpace(priority)) {
+	case "min", "1":
+		return 1
+	case "low", "2":
+		return 2
+	case "high", "4":
+		return 4
+	case "max", "urgent", "5":
+		return 5
+	}
+	return 3
+}
@@ -5,17 +5,30 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 
 	"github.com/murtaza-u/alertfy/internal/alert"
 	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/metrics"
+	"github.com/murtaza-u/alertfy/internal/tracing"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
-// Parser is defines a Parse method to process the alert and extract relevant
-// data.
+var tracer = tracing.Tracer("ntfy")
+
+// Parser is defines methods to process alerts and extract relevant data.
+// Notifications are rendered once for every topic they are sent to, so that
+// each is delivered independently.
 type Parser interface {
-	Parse(context.Context, alert.Alert) *Data
+	// Parse renders the notification for a single alert.
+	Parse(context.Context, alert.Data) ([]Data, error)
+	// ParseGroup renders a single notification for the whole group of
+	// alerts received in a webhook call.
+	ParseGroup(context.Context, alert.Webhook) ([]Data, error)
 }
 
 // NewParser creates a new instance of a parser. The returned parser will use
@@ -28,67 +41,120 @@ func NewParser(conf conf.Ntfy) Parser {
 
 // Parse processes the provided alert and extracts various pieces of data. If
 // any step in the process fails, appropriate error messages are logged, and
-// the method returns nil.
-func (p parser) Parse(ctx context.Context, alert alert.Alert) *Data {
+// the method returns the error.
+func (p parser) Parse(ctx context.Context, data alert.Data) ([]Data, error) {
+	return p.parse(ctx, data)
+}
+
+// ParseGroup processes the provided webhook payload as a whole and extracts
+// various pieces of data. If any step in the process fails, appropriate error
+// messages are logged, and the method returns the error.
+func (p parser) ParseGroup(ctx context.Context, w alert.Webhook) ([]Data, error) {
+	return p.parse(ctx, w)
+}
+
+func (p parser) parse(ctx context.Context, alert any) ([]Data, error) {
+	ctx, span := tracer.Start(ctx, "Parser.Parse")
+	defer span.End()
+
+	_, step := tracer.Start(ctx, "Parser.Title")
 	title, err := p.Title(alert)
+	endStep(step, err)
 	if err != nil {
+		metrics.RenderErrors.WithLabelValues("title").Inc()
 		slog.LogAttrs(
 			ctx,
 			slog.LevelError,
 			"failed to parse title for notification. Aborting",
-			slog.String("fingerprint", alert.Fingerprint),
+			subject(alert),
 			slog.String("error", err.Error()),
 		)
-		return nil
+		return nil, fail(span, fmt.Errorf("parsing title: %w", err))
 	}
 
+	_, step = tracer.Start(ctx, "Parser.Description")
 	desc, err := p.Description(alert)
+	endStep(step, err)
 	if err != nil {
+		metrics.RenderErrors.WithLabelValues("description").Inc()
 		slog.LogAttrs(
 			ctx,
 			slog.LevelError,
 			"failed to parse description for notification. Aborting",
-			slog.String("fingerprint", alert.Fingerprint),
+			subject(alert),
 			slog.String("error", err.Error()),
 		)
-		return nil
+		return nil, fail(span, fmt.Errorf("parsing description: %w", err))
 	}
 
-	topic, err := p.Topic(ctx, alert)
+	stepCtx, step := tracer.Start(ctx, "Parser.Topics")
+	topics, err := p.Topics(stepCtx, alert)
+	endStep(step, err)
 	if err != nil {
+		metrics.RenderErrors.WithLabelValues("topic").Inc()
 		slog.LogAttrs(
 			ctx,
 			slog.LevelError,
 			"failed to parse notification topic. Aborting",
-			slog.String("fingerprint", alert.Fingerprint),
+			subject(alert),
 			slog.String("error", err.Error()),
 		)
+		return nil, fail(span, fmt.Errorf("parsing topic: %w", err))
 	}
 
-	priority, err := p.Priority(ctx, alert)
+	stepCtx, step = tracer.Start(ctx, "Parser.Priority")
+	priority, err := p.Priority(stepCtx, alert)
+	endStep(step, err)
 	if err != nil {
+		metrics.RenderErrors.WithLabelValues("priority").Inc()
 		slog.LogAttrs(
 			ctx,
 			slog.LevelError,
 			"failed to parse notification priority. Defaulting to `default`",
-			slog.String("fingerprint", alert.Fingerprint),
+			subject(alert),
 			slog.String("error", err.Error()),
 		)
 		priority = defaultPriority
 	}
 
-	tags := p.Tags(ctx, alert)
+	stepCtx, step = tracer.Start(ctx, "Parser.Tags")
+	tags := p.Tags(stepCtx, alert)
+	step.End()
 
-	url, err := p.URL(topic)
-	if err != nil {
-		slog.LogAttrs(
-			ctx,
-			slog.LevelError,
-			"failed to get ntfy url. Aborting",
-			slog.String("fingerprint", alert.Fingerprint),
-			slog.String("error", err.Error()),
-		)
-		return nil
+	var webhook *Webhook
+	if p.conf.Notifier == conf.NotifierWebhook {
+		_, step = tracer.Start(ctx, "Parser.Webhook")
+		webhook, err = p.Webhook(alert)
+		endStep(step, err)
+		if err != nil {
+			metrics.RenderErrors.WithLabelValues("webhook").Inc()
+			slog.LogAttrs(
+				ctx,
+				slog.LevelError,
+				"failed to render webhook request for notification. Aborting",
+				subject(alert),
+				slog.String("error", err.Error()),
+			)
+			return nil, fail(span, fmt.Errorf("rendering webhook request: %w", err))
+		}
+	}
+
+	var html string
+	if p.sendsEmail() && p.conf.Email.HTML != nil {
+		_, step = tracer.Start(ctx, "Parser.HTML")
+		html, err = p.HTML(alert)
+		endStep(step, err)
+		if err != nil {
+			metrics.RenderErrors.WithLabelValues("html").Inc()
+			slog.LogAttrs(
+				ctx,
+				slog.LevelError,
+				"failed to parse HTML body for notification. Aborting",
+				subject(alert),
+				slog.String("error", err.Error()),
+			)
+			return nil, fail(span, fmt.Errorf("parsing HTML body: %w", err))
+		}
 	}
 
 	// If the description is empty, send the title as the description so that
@@ -99,13 +165,46 @@ func (p parser) Parse(ctx context.Context, alert alert.Alert) *Data {
 		title = ""
 	}
 
-	return &Data{
-		URL:         url,
-		Title:       title,
-		Description: desc,
-		Tags:        tags,
-		Priority:    priority,
+	notifications := make([]Data, 0, len(topics))
+	for _, topic := range topics {
+		url, err := p.URL(topic)
+		if err != nil {
+			metrics.RenderErrors.WithLabelValues("url").Inc()
+			slog.LogAttrs(
+				ctx,
+				slog.LevelError,
+				"failed to get ntfy url. Aborting",
+				subject(alert),
+				slog.String("error", err.Error()),
+			)
+			return nil, fail(span, fmt.Errorf("getting ntfy url: %w", err))
+		}
+		notifications = append(notifications, Data{
+			URL:         url,
+			Topic:       topic,
+			Title:       title,
+			Description: desc,
+			Tags:        tags,
+			Priority:    priority,
+			Webhook:     webhook,
+			HTML:        html,
+		})
+	}
+	return notifications, nil
+}
+
+// endStep ends the span of a parsing step, recording the error, if any.
+func endStep(span trace.Span, err error) {
+	if err != nil {
+		tracing.Fail(span, err)
 	}
+	span.End()
+}
+
+// fail records the error on the span and returns it.
+func fail(span trace.Span, err error) error {
+	tracing.Fail(span, err)
+	return err
 }
 
 // parser is the default Parser implememtation.
@@ -114,8 +213,11 @@ type parser struct {
 }
 
 // URL constructs the URL for a given topic by appending the topic to the base
-// URL.
+// URL. For the webhook notifier, it returns the webhook URL as is.
 func (p parser) URL(topic string) (string, error) {
+	if p.conf.Notifier == conf.NotifierWebhook {
+		return p.conf.Webhook.URL, nil
+	}
 	base := p.conf.BaseURL
 	url, err := url.JoinPath(base, topic)
 	if err != nil {
@@ -124,9 +226,20 @@ func (p parser) URL(topic string) (string, error) {
 	return url, nil
 }
 
+// subject returns the log attribute identifying the rendered alert or group.
+func subject(v any) slog.Attr {
+	switch v := v.(type) {
+	case alert.Data:
+		return slog.String("fingerprint", v.Fingerprint)
+	case alert.Webhook:
+		return slog.String("groupKey", v.GroupKey)
+	}
+	return slog.Attr{}
+}
+
 // Title generates the title for the alert by executing the template stored in
 // the configuration.
-func (p parser) Title(alert alert.Alert) (string, error) {
+func (p parser) Title(alert any) (string, error) {
 	buf := new(bytes.Buffer)
 	err := p.conf.Notification.Title.Execute(buf, alert)
 	if err != nil {
@@ -137,7 +250,7 @@ func (p parser) Title(alert alert.Alert) (string, error) {
 
 // Description generates the description for the alert by executing the
 // template stored in the configuration.
-func (p parser) Description(alert alert.Alert) (string, error) {
+func (p parser) Description(alert any) (string, error) {
 	buf := new(bytes.Buffer)
 	err := p.conf.Notification.Description.Execute(buf, alert)
 	if err != nil {
@@ -146,12 +259,110 @@ func (p parser) Description(alert alert.Alert) (string, error) {
 	return buf.String(), nil
 }
 
-// Topic extracts the topic from the alert by evaluating the topic expression
-// defined in the configuration. If the topic expression is nil, it simply
-// returns the topic text. Otherwise, it evaluates the expression and returns
-// the result.
-func (p parser) Topic(c context.Context, alert alert.Alert) (string, error) {
-	topic := p.conf.Notification.Topic
+// sendsEmail reports whether notifications may be sent by email, either by
+// the email notifier or by the fallback.
+func (p parser) sendsEmail() bool {
+	return p.conf.Notifier == conf.NotifierEmail || p.conf.Fallback.Enable
+}
+
+// HTML generates the HTML body of emails for the alert by executing the
+// template stored in the configuration.
+func (p parser) HTML(alert any) (string, error) {
+	buf := new(bytes.Buffer)
+	err := p.conf.Email.HTML.Execute(buf, alert)
+	if err != nil {
+		return "", fmt.Errorf("executing template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// Webhook renders the method, headers and body of the webhook request for the
+// alert by executing the templates stored in the configuration.
+func (p parser) Webhook(alert any) (*Webhook, error) {
+	c := p.conf.Webhook
+	execute := func(t *conf.Template) (string, error) {
+		if t == nil {
+			return "", nil
+		}
+		buf := new(bytes.Buffer)
+		if err := t.Execute(buf, alert); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+
+	method, err := execute(c.Method)
+	if err != nil {
+		return nil, fmt.Errorf("executing method template: %w", err)
+	}
+	w := &Webhook{Method: strings.ToUpper(strings.TrimSpace(method))}
+	if w.Method == "" {
+		w.Method = http.MethodPost
+	}
+	if len(c.Headers) != 0 {
+		w.Headers = make(map[string]string, len(c.Headers))
+	}
+	for name, t := range c.Headers {
+		value, err := execute(t)
+		if err != nil {
+			return nil, fmt.Errorf("executing template of header %q: %w", name, err)
+		}
+		w.Headers[name] = value
+	}
+	w.Body, err = execute(c.Body)
+	if err != nil {
+		return nil, fmt.Errorf("executing body template: %w", err)
+	}
+	return w, nil
+}
+
+// Topics returns the topics the notification for the alert is sent to. If
+// the configuration defines a list of topics, it evaluates the topic of every
+// entry whose condition evaluates to true or is empty, skipping duplicates.
+// Otherwise, it returns the single topic defined in the configuration.
+func (p parser) Topics(c context.Context, alert any) ([]string, error) {
+	n := p.conf.Notification
+	if len(n.Topics) == 0 {
+		topic, err := evalTopic(c, n.Topic, alert)
+		if err != nil {
+			return nil, err
+		}
+		return []string{topic}, nil
+	}
+
+	var topics []string
+	for _, t := range n.Topics {
+		if t.Condition.Text != "" {
+			include, err := t.Condition.Evaluable.EvalBool(c, alert)
+			if err != nil {
+				return nil, fmt.Errorf("evaluating condition %q: %w",
+					t.Condition.Text, err)
+			}
+			if !include {
+				slog.LogAttrs(
+					c,
+					slog.LevelDebug,
+					"topic condition evaluated to false",
+					slog.String("condition", t.Condition.Text),
+					subject(alert),
+				)
+				continue
+			}
+		}
+		topic, err := evalTopic(c, t.Topic, alert)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(topics, topic) {
+			topics = append(topics, topic)
+		}
+	}
+	return topics, nil
+}
+
+// evalTopic returns the topic text if the topic expression is nil. Otherwise,
+// it evaluates the expression and returns the result.
+func evalTopic(c context.Context, topic conf.StringExpr, alert any) (string, error) {
 	if topic.Expr == nil {
 		return topic.Text, nil
 	}
@@ -164,19 +375,23 @@ func (p parser) Topic(c context.Context, alert alert.Alert) (string, error) {
 
 // Priority extracts the priority from the alert by evaluating the priority
 // expression defined in the configuration. If the priority text is not set, it
-// defaults to a `defaultPriority`. Otherwise, it evaluates the expression and
-// returns the result.
-func (p parser) Priority(c context.Context, alert alert.Alert) (string, error) {
+// defaults to a `defaultPriority`. If the priority expression is nil, it
+// returns the priority text. Otherwise, it evaluates the expression and returns
+// the result.
+func (p parser) Priority(c context.Context, alert any) (string, error) {
 	priority := p.conf.Notification.Priority
 	if priority.Text == "" {
 		slog.LogAttrs(
 			c,
 			slog.LevelDebug,
 			"ntfy.notification.priority not set. Defaulting to `default`",
-			slog.String("fingerprint", alert.Fingerprint),
+			subject(alert),
 		)
 		return defaultPriority, nil
 	}
+	if priority.Expr == nil {
+		return priority.Text, nil
+	}
 	out, err := priority.Expr.Evaluable.EvalString(c, alert)
 	if err != nil {
 		return "", fmt.Errorf("evaluating expression: %w", err)
@@ -187,7 +402,7 @@ func (p parser) Priority(c context.Context, alert alert.Alert) (string, error) {
 // Tags constructs a comma-separated list of tags for the alert based on the
 // tags defined in the configuration. Each tag is included if its condition
 // evaluates to true or if no condition is specified.
-func (p parser) Tags(c context.Context, alert alert.Alert) string {
+func (p parser) Tags(c context.Context, alert any) string {
 	tags := p.conf.Notification.Tags
 	if len(tags) == 0 {
 		return ""
@@ -204,13 +419,14 @@ func (p parser) Tags(c context.Context, alert alert.Alert) string {
 		}
 		include, err := tag.Condition.Evaluable.EvalBool(c, alert)
 		if err != nil {
+			metrics.RenderErrors.WithLabelValues("tags").Inc()
 			slog.LogAttrs(
 				c,
 				slog.LevelError,
 				"evaluating tag condition failed. Skipping tag",
 				slog.String("error", err.Error()),
 				slog.String("condition", tag.Condition.Text),
-				slog.String("fingerprint", alert.Fingerprint),
+				subject(alert),
 			)
 			continue
 		}
@@ -220,7 +436,7 @@ func (p parser) Tags(c context.Context, alert alert.Alert) string {
 				slog.LevelDebug,
 				"tag condition evaluated to false",
 				slog.String("condition", tag.Condition.Text),
-				slog.String("fingerprint", alert.Fingerprint),
+				subject(alert),
 			)
 			continue
 		}
@@ -0,0 +1,58 @@
+package ntfy
+
+import (
+	"context"
+
+	"github.com/murtaza-u/alertfy/internal/alert"
+	"github.com/murtaza-u/alertfy/internal/conf"
+	"github.com/murtaza-u/alertfy/internal/route"
+)
+
+// Rendered are the notifications rendered for an alert, or for the whole
+// group of alerts in "group" mode, and one of the routes it matched.
+type Rendered struct {
+	// Alert is the alert the notifications are rendered for. Nil in "group"
+	// mode.
+	Alert *alert.Alert
+	// Route is the matched route.
+	Route route.Match
+	// Notifications contains a notification for every topic of the route.
+	// Empty if no topic matched or rendering failed.
+	Notifications []Data
+	// Err is the error rendering failed with, if any.
+	Err error
+}
+
+// Render renders the notifications for the webhook payload according to the
+// notification mode of the ntfy configuration n: for every alert, or for the
+// whole group in "group" mode, and every route it matches.
+func Render(ctx context.Context, n conf.Ntfy, w alert.Webhook) []Rendered {
+	var out []Rendered
+	if n.Notification.Mode == conf.ModeGroup {
+		for _, m := range route.Find(n, w.CommonLabels) {
+			data, err := RenderRoute(ctx, n, m, w, nil)
+			out = append(out, Rendered{Route: m, Notifications: data, Err: err})
+		}
+		return out
+	}
+	for i := range w.Alerts {
+		a := &w.Alerts[i]
+		for _, m := range route.Find(n, a.Labels) {
+			data, err := RenderRoute(ctx, n, m, w, a)
+			out = append(out, Rendered{Alert: a, Route: m, Notifications: data, Err: err})
+		}
+	}
+	return out
+}
+
+// RenderRoute renders the notifications for a single alert of the webhook
+// payload, or for the whole group if a is nil, using the notification
+// configuration of the route.
+func RenderRoute(ctx context.Context, n conf.Ntfy, m route.Match, w alert.Webhook, a *alert.Alert) ([]Data, error) {
+	n.Notification = m.Notification
+	p := NewParser(n)
+	if a == nil {
+		return p.ParseGroup(ctx, w)
+	}
+	return p.Parse(ctx, alert.NewData(w, *a))
+}
@@ -0,0 +1,133 @@
+package outbox
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	entryExt = ".json"
+	tmpExt   = ".tmp"
+)
+
+// Outbox is a durable, write-ahead store of pending records. Each record is
+// kept in its own file inside the outbox directory and is written atomically,
+// so a crash never leaves a partially written record behind.
+type Outbox struct {
+	dir string
+}
+
+// Entry is a record read back from the outbox.
+type Entry struct {
+	ID   string
+	Data []byte
+}
+
+// Open opens the outbox stored in the provided directory, creating the
+// directory if it does not exist. Leftovers of interrupted writes are removed.
+func Open(dir string) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating outbox directory: %w", err)
+	}
+	tmps, err := filepath.Glob(filepath.Join(dir, "*"+tmpExt))
+	if err != nil {
+		return nil, fmt.Errorf("listing outbox directory: %w", err)
+	}
+	for _, tmp := range tmps {
+		if err := os.Remove(tmp); err != nil {
+			return nil, fmt.Errorf("removing incomplete record: %w", err)
+		}
+	}
+	return &Outbox{dir: dir}, nil
+}
+
+// NewID generates a unique record ID. IDs sort in the order they were
+// generated.
+func NewID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%020d-%s", time.Now().UnixNano(), hex.EncodeToString(b))
+}
+
+// Put durably stores the record with the provided ID. It returns only after
+// the record has been flushed to disk.
+func (o *Outbox) Put(id string, data []byte) error {
+	tmp := filepath.Join(o.dir, id+tmpExt)
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("creating record: %w", err)
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing record: %w", err)
+	}
+	if err := os.Rename(tmp, o.path(id)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("committing record: %w", err)
+	}
+	return o.syncDir()
+}
+
+// Delete removes the record with the provided ID. Deleting a record that does
+// not exist is not an error.
+func (o *Outbox) Delete(id string) error {
+	err := os.Remove(o.path(id))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("deleting record: %w", err)
+	}
+	return nil
+}
+
+// List returns all records in the outbox, oldest first.
+func (o *Outbox) List() ([]Entry, error) {
+	files, err := filepath.Glob(filepath.Join(o.dir, "*"+entryExt))
+	if err != nil {
+		return nil, fmt.Errorf("listing outbox directory: %w", err)
+	}
+	sort.Strings(files)
+
+	entries := make([]Entry, 0, len(files))
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading record: %w", err)
+		}
+		entries = append(entries, Entry{
+			ID:   strings.TrimSuffix(filepath.Base(f), entryExt),
+			Data: data,
+		})
+	}
+	return entries, nil
+}
+
+func (o *Outbox) path(id string) string {
+	return filepath.Join(o.dir, id+entryExt)
+}
+
+// syncDir flushes the directory entry, so that a committed record survives a
+// crash.
+func (o *Outbox) syncDir() error {
+	d, err := os.Open(o.dir)
+	if err != nil {
+		return fmt.Errorf("opening outbox directory: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("syncing outbox directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package outbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutbox(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+
+	// leftover of an interrupted write
+	err := os.WriteFile(filepath.Join(dir, "partial"+tmpExt), []byte("{"), 0o600)
+	a.NoError(err)
+
+	ob, err := Open(dir)
+	if !a.NoError(err) {
+		return
+	}
+
+	ids := []string{NewID(), NewID(), NewID()}
+	for i, id := range ids {
+		a.NoError(ob.Put(id, []byte{byte('a' + i)}))
+	}
+	a.NoError(ob.Delete(ids[1]))
+	a.NoError(ob.Delete("missing"))
+
+	// reopening must only yield the committed records, oldest first
+	ob, err = Open(dir)
+	if !a.NoError(err) {
+		return
+	}
+	entries, err := ob.List()
+	if a.NoError(err) {
+		a.Equal([]Entry{
+			{ID: ids[0], Data: []byte("a")},
+			{ID: ids[2], Data: []byte("c")},
+		}, entries)
+	}
+}
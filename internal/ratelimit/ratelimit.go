@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter maintains a token bucket per key. Each bucket holds up to burst
+// tokens and is refilled at the configured rate.
+type Limiter struct {
+	rate  float64 // tokens per second
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// New creates a limiter allowing n events per period, with bursts of up to
+// burst events.
+func New(n int, per time.Duration, burst int) *Limiter {
+	return &Limiter{
+		rate:    float64(n) / per.Seconds(),
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow reports whether an event for the provided key may happen now, taking a
+// token from the key's bucket if so.
+func (l *Limiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Prune forgets the buckets that have refilled completely, as they are
+// indistinguishable from new ones.
+func (l *Limiter) Prune() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
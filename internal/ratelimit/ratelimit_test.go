@@ -0,0 +1,32 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	a := assert.New(t)
+	now := time.Now()
+	l := New(6, time.Minute, 2) // one token every 10s
+	l.now = func() time.Time { return now }
+
+	a.True(l.Allow("a"))
+	a.True(l.Allow("a"))
+	a.False(l.Allow("a"), "burst exhausted")
+	a.True(l.Allow("b"), "buckets are per key")
+
+	now = now.Add(5 * time.Second)
+	a.False(l.Allow("a"), "half a token refilled")
+	now = now.Add(5 * time.Second)
+	a.True(l.Allow("a"), "one token refilled")
+
+	now = now.Add(time.Hour)
+	l.Prune()
+	a.Empty(l.buckets, "refilled buckets are pruned")
+	a.True(l.Allow("a"))
+	a.True(l.Allow("a"))
+	a.False(l.Allow("a"), "refill is capped at burst")
+}
@@ -0,0 +1,100 @@
+// Package route matches alerts against the routing tree of a ntfy
+// configuration.
+package route
+
+import (
+	"strconv"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+)
+
+// Match is a route matched by an alert.
+type Match struct {
+	// ID identifies the route. It is the name of the route if set, and its
+	// position in the tree otherwise. Empty if no route matched.
+	ID string
+	// Notification is the notification configuration of the route, with the
+	// fields the route does not set inherited from its parents.
+	Notification conf.Notification
+}
+
+// Find returns the routes of the ntfy configuration matching the labels, in
+// order. If no route matches, a single match with an empty ID and the
+// notification configuration of n is returned.
+func Find(n conf.Ntfy, labels map[string]string) []Match {
+	matches := find(n.Routes, "", n.Notification, labels)
+	if len(matches) == 0 {
+		return []Match{{Notification: n.Notification}}
+	}
+	return matches
+}
+
+// Get returns the route with the provided ID if it matches the labels.
+func Get(n conf.Ntfy, id string, labels map[string]string) (Match, bool) {
+	for _, m := range Find(n, labels) {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return Match{}, false
+}
+
+func find(routes []conf.Route, parent string, inherited conf.Notification, labels map[string]string) []Match {
+	var matches []Match
+	for i, r := range routes {
+		if !matchesAll(r.Matchers, labels) {
+			continue
+		}
+		pos := strconv.Itoa(i)
+		if parent != "" {
+			pos = parent + "." + pos
+		}
+		notification := inherit(inherited, r.Notification)
+
+		children := find(r.Routes, pos, notification, labels)
+		if len(children) == 0 {
+			id := r.Name
+			if id == "" {
+				id = pos
+			}
+			children = []Match{{ID: id, Notification: notification}}
+		}
+		matches = append(matches, children...)
+		if !r.Continue {
+			break
+		}
+	}
+	return matches
+}
+
+func matchesAll(matchers []conf.Matcher, labels map[string]string) bool {
+	for _, m := range matchers {
+		if !m.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// inherit returns the notification configuration of the parent overridden by
+// the fields set by the route.
+func inherit(parent, route conf.Notification) conf.Notification {
+	n := parent
+	if route.Topic.Text != "" || route.Topics != nil {
+		n.Topic = route.Topic
+		n.Topics = route.Topics
+	}
+	if route.Priority.Text != "" {
+		n.Priority = route.Priority
+	}
+	if route.Tags != nil {
+		n.Tags = route.Tags
+	}
+	if route.Title != nil {
+		n.Title = route.Title
+	}
+	if route.Description != nil {
+		n.Description = route.Description
+	}
+	return n
+}
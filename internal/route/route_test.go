@@ -0,0 +1,114 @@
+package route
+
+import (
+	"testing"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func matchers(t *testing.T, ms ...string) []conf.Matcher {
+	out := make([]conf.Matcher, len(ms))
+	for i, m := range ms {
+		require.NoError(t, out[i].UnmarshalText([]byte(m)))
+	}
+	return out
+}
+
+func topic(s string) conf.Notification {
+	return conf.Notification{Topic: conf.StringExpr{Text: s}}
+}
+
+func TestFind(t *testing.T) {
+	n := conf.Ntfy{
+		Notification: conf.Notification{
+			Topic:    conf.StringExpr{Text: "default"},
+			Priority: conf.StringExpr{Text: "low"},
+		},
+		Routes: []conf.Route{
+			{
+				Name:         "db",
+				Matchers:     matchers(t, `team="db"`),
+				Notification: topic("db"),
+				Routes: []conf.Route{
+					{
+						Matchers: matchers(t, `severity="critical"`),
+						Notification: conf.Notification{
+							Priority: conf.StringExpr{Text: "urgent"},
+						},
+					},
+				},
+			},
+			{
+				Name:         "audit",
+				Matchers:     matchers(t, `severity=~"critical|page"`),
+				Notification: topic("audit"),
+				Continue:     true,
+			},
+			{
+				Name:         "oncall",
+				Matchers:     matchers(t, `severity="critical"`),
+				Notification: topic("oncall"),
+			},
+			{
+				Name:         "never",
+				Notification: topic("never"),
+			},
+		},
+	}
+
+	type match struct{ id, topic, priority string }
+	find := func(labels map[string]string) []match {
+		var out []match
+		for _, m := range Find(n, labels) {
+			out = append(out, match{
+				m.ID, m.Notification.Topic.Text, m.Notification.Priority.Text,
+			})
+		}
+		return out
+	}
+
+	a := assert.New(t)
+	a.Equal([]match{{"db", "db", "low"}},
+		find(map[string]string{"team": "db"}),
+		"parent matches without matching child")
+	a.Equal([]match{{"0.0", "db", "urgent"}},
+		find(map[string]string{"team": "db", "severity": "critical"}),
+		"child inherits topic, stops evaluation")
+	a.Equal([]match{{"audit", "audit", "low"}, {"oncall", "oncall", "low"}},
+		find(map[string]string{"severity": "critical"}),
+		"continue evaluates following siblings")
+	a.Equal([]match{{"audit", "audit", "low"}, {"never", "never", "low"}},
+		find(map[string]string{"severity": "page"}),
+		"route without matchers matches everything")
+
+	n.Routes = n.Routes[:3]
+	a.Equal([]match{{"", "default", "low"}},
+		find(map[string]string{"team": "web"}),
+		"no route matches")
+
+	m, ok := Get(n, "oncall", map[string]string{"severity": "critical"})
+	a.True(ok)
+	a.Equal("oncall", m.Notification.Topic.Text)
+	_, ok = Get(n, "oncall", map[string]string{"severity": "page"})
+	a.False(ok)
+}
+
+func TestInheritTopics(t *testing.T) {
+	a := assert.New(t)
+	topics := conf.Notification{Topics: []conf.Topic{
+		{Topic: conf.StringExpr{Text: "team"}},
+		{Topic: conf.StringExpr{Text: "sre"}},
+	}}
+
+	n := inherit(topics, conf.Notification{})
+	a.Len(n.Topics, 2, "topics are inherited")
+	n = inherit(topics, topic("db"))
+	a.Equal("db", n.Topic.Text)
+	a.Empty(n.Topics, "topic replaces the topics of the parent")
+	n = inherit(topic("db"), topics)
+	a.Empty(n.Topic.Text, "topics replace the topic of the parent")
+	a.Len(n.Topics, 2)
+}
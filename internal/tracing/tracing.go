@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/murtaza-u/alertfy/internal/conf"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer returns the tracer used to instrument the provided package.
+func Tracer(pkg string) trace.Tracer {
+	return otel.Tracer("github.com/murtaza-u/alertfy/internal/" + pkg)
+}
+
+// Setup configures the global tracer provider and the W3C trace context
+// propagator. It returns a function that flushes pending spans and shuts the
+// provider down. If tracing is disabled, spans are discarded and the returned
+// function does nothing.
+func Setup(c conf.Tracing) (func(context.Context) error, error) {
+	if !c.Enable {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := newExporter(c)
+	if err != nil {
+		return nil, fmt.Errorf("creating %s exporter: %w", c.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(c.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("creating resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(
+			sdktrace.TraceIDRatioBased(c.SampleRatio),
+		)),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return tp.Shutdown, nil
+}
+
+func newExporter(c conf.Tracing) (sdktrace.SpanExporter, error) {
+	if c.Exporter == conf.ExporterStdout {
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(c.Endpoint)}
+	if c.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	return otlptracehttp.New(context.Background(), opts...)
+}
+
+// Inject returns the trace context of the provided context as a map, so that
+// it can be carried along with work that is processed asynchronously.
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// Extract returns a copy of the provided context carrying the trace context
+// previously returned by Inject.
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(carrier))
+}
+
+// Fail records the error on the span and marks the span as failed.
+func Fail(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}